@@ -0,0 +1,119 @@
+package selina
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotAcked is the nack error of messages released by FreeBuffer
+// without being acknowledged, i.e. dropped by a worker or lost on cancellation
+var ErrNotAcked = errors.New("message released without ack")
+
+// ackState is shared by a source message and all messages derived from it
+type ackState struct {
+	mtx     sync.Mutex
+	pending int
+	err     error
+	fn      func(error)
+}
+
+func (s *ackState) add(n int) {
+	s.mtx.Lock()
+	s.pending += n
+	s.mtx.Unlock()
+}
+
+func (s *ackState) done(err error) {
+	s.mtx.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.pending--
+	finished := s.pending == 0
+	s.mtx.Unlock()
+	if finished {
+		s.fn(s.err)
+	}
+}
+
+var (
+	acks sync.Map
+	// tracked avoid a map lookup in FreeBuffer when acks are not used
+	tracked int64
+)
+
+// OnAck track msg, fn is called once when msg and every message derived
+// from it (see Forward) in all branches of the pipeline are acknowledged,
+// err is the first nack error or nil, sources call it before sending msg
+func OnAck(msg *bytes.Buffer, fn func(err error)) {
+	if msg == nil || fn == nil {
+		return
+	}
+	track(msg, &ackState{pending: 1, fn: fn})
+}
+
+func track(msg *bytes.Buffer, s *ackState) {
+	if _, loaded := acks.Swap(msg, s); !loaded {
+		atomic.AddInt64(&tracked, 1)
+	}
+}
+
+// derive track to as a message derived from from, from is still tracked
+func derive(from, to *bytes.Buffer) {
+	s, ok := lookup(from)
+	if !ok {
+		return
+	}
+	s.add(1)
+	track(to, s)
+}
+
+// Forward move tracking of from to all messages in to, workers that create
+// new messages from its input must call it before FreeBuffer(from)
+func Forward(from *bytes.Buffer, to ...*bytes.Buffer) {
+	for _, t := range to {
+		if t != nil && t != from {
+			derive(from, t)
+		}
+	}
+	Ack(from, nil)
+}
+
+// Ack acknowledge msg, a non nil err is a nack, after this call msg is not tracked
+func Ack(msg *bytes.Buffer, err error) {
+	Detach(msg)(err)
+}
+
+// Detach stop tracking msg and returns a function that acknowledge it,
+// sinks use it to ack a message after FreeBuffer, i.e. when data is flushed,
+// returned function must be called once
+func Detach(msg *bytes.Buffer) func(err error) {
+	if msg == nil || atomic.LoadInt64(&tracked) == 0 {
+		return func(error) {}
+	}
+	s, ok := acks.LoadAndDelete(msg)
+	if !ok {
+		return func(error) {}
+	}
+	atomic.AddInt64(&tracked, -1)
+	return s.(*ackState).done
+}
+
+func lookup(msg *bytes.Buffer) (*ackState, bool) {
+	if msg == nil || atomic.LoadInt64(&tracked) == 0 {
+		return nil, false
+	}
+	s, ok := acks.Load(msg)
+	if !ok {
+		return nil, false
+	}
+	return s.(*ackState), true
+}
+
+// IsTracked returns true if msg must be acknowledged (see OnAck)
+func IsTracked(msg *bytes.Buffer) bool {
+	_, ok := lookup(msg)
+	return ok
+}
@@ -0,0 +1,137 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+// ackSource send values and record its acks
+type ackSource struct {
+	values []string
+	mtx    sync.Mutex
+	acks   map[string]error
+}
+
+func (s *ackSource) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	s.acks = make(map[string]error)
+	for _, v := range s.values {
+		v := v
+		msg := selina.GetBuffer()
+		msg.WriteString(v)
+		selina.OnAck(msg, func(err error) {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			s.acks[v] = err
+		})
+		if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ackSource) result(v string) (error, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	err, ok := s.acks[v]
+	return err, ok
+}
+
+// ackWriter ack every message except nack
+type ackWriter struct {
+	nack string
+}
+
+func (w *ackWriter) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			if msg.String() != w.nack {
+				selina.Ack(msg, nil)
+			}
+			selina.FreeBuffer(msg)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestAckFanOut(t *testing.T) {
+	src := &ackSource{values: []string{"a", "b", "c"}}
+	upper := selina.Map(func(_ context.Context, in string) (string, error) {
+		if in == "c" {
+			return "", selina.ErrSkipMessage
+		}
+		return strings.ToUpper(in), nil
+	})
+	p, err := selina.NewBuilder().
+		From(selina.NewNode("src", src)).
+		Then(selina.NewNode("upper", upper)).
+		Branch(
+			func(b *selina.Builder) { b.To(selina.NewNode("left", &ackWriter{})) },
+			func(b *selina.Builder) { b.To(selina.NewNode("right", &ackWriter{nack: "B"})) },
+		).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	tests := []struct {
+		value string
+		want  error
+	}{
+		{value: "a", want: nil},
+		{value: "b", want: selina.ErrNotAcked},
+		{value: "c", want: nil},
+	}
+	for _, tt := range tests {
+		got, ok := src.result(tt.value)
+		if !ok || !errors.Is(got, tt.want) {
+			t.Fatalf("ack %s = %v (%v), want %v", tt.value, got, ok, tt.want)
+		}
+	}
+}
+
+func TestAckEndOfPipeline(t *testing.T) {
+	src := &ackSource{values: []string{"a"}}
+	p := selina.LinealPipeline(selina.NewNode("src", src), selina.NewNode("pass", &dummyWorker{}))
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if err, ok := src.result("a"); !ok || err != nil {
+		t.Fatalf("ack = %v (%v)", err, ok)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	msg := selina.GetBuffer()
+	var got []error
+	selina.OnAck(msg, func(err error) { got = append(got, err) })
+	out := selina.GetBuffer()
+	selina.Forward(msg, out)
+	selina.FreeBuffer(msg)
+	if !selina.IsTracked(out) || selina.IsTracked(msg) {
+		t.Fatalf("Forward() did not move tracking")
+	}
+	done := selina.Detach(out)
+	selina.FreeBuffer(out)
+	if len(got) != 0 {
+		t.Fatalf("acked before Detach func is called: %v", got)
+	}
+	done(nil)
+	if len(got) != 1 || got[0] != nil {
+		t.Fatalf("acks = %v", got)
+	}
+}
@@ -0,0 +1,158 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrInvalidBudget is returned by NewBudget when limit is not greater than zero
+var ErrInvalidBudget = errors.New("budget limit must be greater than zero")
+
+// Budget limit bytes of messages in flight in a pipeline, see WithMemoryBudget
+type Budget struct {
+	limit int64
+	mtx   sync.Mutex
+	used  int64
+	// wake is closed and replaced every time bytes are released
+	wake chan struct{}
+}
+
+// NewBudget create a Budget of limit bytes
+func NewBudget(limit int64) (*Budget, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidBudget
+	}
+	return &Budget{limit: limit, wake: make(chan struct{})}, nil
+}
+
+// Limit returns max bytes in flight
+func (b *Budget) Limit() int64 {
+	return b.limit
+}
+
+// Used returns bytes of messages sent and not freed yet
+func (b *Budget) Used() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.used
+}
+
+// acquire take n bytes, when block is true it waits until there are enough free bytes,
+// a message bigger than limit is allowed when budget is empty so it never blocks forever
+func (b *Budget) acquire(ctx context.Context, n int64, block bool) error {
+	for {
+		b.mtx.Lock()
+		if !block || b.used == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mtx.Unlock()
+			return nil
+		}
+		wake := b.wake
+		b.mtx.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *Budget) release(n int64) {
+	b.mtx.Lock()
+	b.used -= n
+	close(b.wake)
+	b.wake = make(chan struct{})
+	b.mtx.Unlock()
+}
+
+type budgetKey struct{}
+
+// WithMemoryBudget returns a context that carries b, pass it to Pipeliner.Run
+// to limit bytes in flight, nodes without upstream block on SendContext while
+// budget is exhausted, other nodes only account its messages so they never deadlock
+func WithMemoryBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+func budgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// budgetHandle is the budget of a node, bytes are its outstanding bytes
+type budgetHandle struct {
+	budget *Budget
+	block  bool
+	bytes  *int64
+}
+
+type budgetHandleKey struct{}
+
+func withBudgetHandle(ctx context.Context, h *budgetHandle) context.Context {
+	return context.WithValue(ctx, budgetHandleKey{}, h)
+}
+
+type charge struct {
+	h *budgetHandle
+	n int64
+}
+
+var (
+	charges sync.Map
+	// charged avoid a map lookup in FreeBuffer when there is no budget
+	charged int64
+)
+
+// chargeBudget account msg in budget of node that runs in ctx
+func chargeBudget(ctx context.Context, msg *bytes.Buffer) error {
+	h, _ := ctx.Value(budgetHandleKey{}).(*budgetHandle)
+	if h == nil || msg == nil || isMarker(msg) {
+		return nil
+	}
+	if _, ok := charges.Load(msg); ok {
+		return nil
+	}
+	n := int64(msg.Len())
+	if err := h.budget.acquire(ctx, n, h.block); err != nil {
+		return err
+	}
+	atomic.AddInt64(h.bytes, n)
+	atomic.AddInt64(&charged, 1)
+	charges.Store(msg, &charge{h: h, n: n})
+	return nil
+}
+
+// chargeCopy account cp like its original msg, i.e. Broadcaster copies
+func chargeCopy(msg, cp *bytes.Buffer) {
+	if atomic.LoadInt64(&charged) == 0 {
+		return
+	}
+	c, ok := charges.Load(msg)
+	if !ok {
+		return
+	}
+	h := c.(*charge).h
+	n := int64(cp.Len())
+	_ = h.budget.acquire(context.Background(), n, false)
+	atomic.AddInt64(h.bytes, n)
+	atomic.AddInt64(&charged, 1)
+	charges.Store(cp, &charge{h: h, n: n})
+}
+
+// releaseBudget return bytes of msg to its budget
+func releaseBudget(msg *bytes.Buffer) {
+	if atomic.LoadInt64(&charged) == 0 {
+		return
+	}
+	c, ok := charges.LoadAndDelete(msg)
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&charged, -1)
+	ch := c.(*charge)
+	atomic.AddInt64(ch.h.bytes, -ch.n)
+	ch.h.budget.release(ch.n)
+}
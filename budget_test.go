@@ -0,0 +1,85 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+// holdWriter keep messages without free them until release is closed
+type holdWriter struct {
+	received int32
+	release  chan struct{}
+}
+
+func (w *holdWriter) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	var held []*bytes.Buffer
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			atomic.AddInt32(&w.received, 1)
+			select {
+			case <-w.release:
+				selina.FreeBuffer(msg)
+			default:
+				held = append(held, msg)
+			}
+		case <-w.release:
+			for _, m := range held {
+				selina.FreeBuffer(m)
+			}
+			held = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	msg := strings.Repeat("x", 100)
+	budget, err := selina.NewBudget(250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := selina.NewNode("src", &sliceReader{values: []string{msg, msg, msg, msg, msg}})
+	w := &holdWriter{release: make(chan struct{})}
+	sink := selina.NewNode("sink", w)
+	p := selina.LinealPipeline(src, sink)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- p.Run(selina.WithMemoryBudget(context.Background(), budget))
+	}()
+	for atomic.LoadInt32(&w.received) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&w.received); got != 2 {
+		t.Fatalf("received = %d, source must be blocked", got)
+	}
+	if budget.Used() != 200 || p.Stats()[src.ID()].OutstandingBytes != 200 {
+		t.Fatalf("Used() = %d, OutstandingBytes = %d", budget.Used(), p.Stats()[src.ID()].OutstandingBytes)
+	}
+	close(w.release)
+	if err := <-errC; err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if budget.Used() != 0 || atomic.LoadInt32(&w.received) != 5 {
+		t.Fatalf("Used() = %d, received = %d", budget.Used(), w.received)
+	}
+}
+
+func TestNewBudgetInvalid(t *testing.T) {
+	if _, err := selina.NewBudget(0); !errors.Is(err, selina.ErrInvalidBudget) {
+		t.Fatalf("NewBudget() err = %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package selina
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidGraph is returned by Builder.Build when pipeline graph is not valid
+var ErrInvalidGraph = errors.New("invalid pipeline graph")
+
+// endpoint is a node or a reference to a node name resolved on Build
+type endpoint struct {
+	node *Node
+	ref  string
+}
+
+func (e endpoint) String() string {
+	if e.node != nil {
+		return e.node.Name()
+	}
+	return e.ref
+}
+
+type builderEdge struct {
+	from endpoint
+	to   endpoint
+	opts EdgeOptions
+}
+
+// graph is shared by a Builder and all its branches
+type graph struct {
+	nodes []*Node
+	seen  map[*Node]bool
+	edges []builderEdge
+	errs  []string
+}
+
+func (g *graph) add(n *Node) {
+	if !g.seen[n] {
+		g.seen[n] = true
+		g.nodes = append(g.nodes, n)
+	}
+}
+
+func (g *graph) fail(format string, args ...interface{}) {
+	g.errs = append(g.errs, fmt.Sprintf(format, args...))
+}
+
+// Builder create a Pipeliner step by step, it supports fan-out (Branch, To),
+// fan-in (Merge) and references to nodes by name, forward references are allowed.
+// Errors are reported by Build, so calls can be chained
+//
+//	p, err := selina.NewBuilder().
+//		From(src).
+//		Then(parse).
+//		Branch(
+//			func(b *selina.Builder) { b.Then(valid) },
+//			func(b *selina.Builder) { b.Then(audit).To(auditSink) },
+//		).
+//		To(sink).
+//		Build()
+type Builder struct {
+	g     *graph
+	tails []endpoint
+}
+
+// NewBuilder create an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{g: &graph{seen: make(map[*Node]bool)}}
+}
+
+// From start a new chain from nodes, next step receives messages of all of them
+func (b *Builder) From(nodes ...*Node) *Builder {
+	b.tails = nil
+	for _, n := range nodes {
+		if n == nil {
+			b.g.fail("From: nil node")
+			continue
+		}
+		b.g.add(n)
+		b.tails = append(b.tails, endpoint{node: n})
+	}
+	return b
+}
+
+// Then chain current step to next, next become current step
+func (b *Builder) Then(next *Node) *Builder {
+	return b.ThenWith(next, EdgeOptions{})
+}
+
+// ThenWith is like Then with custom EdgeOptions for every incoming edge
+func (b *Builder) ThenWith(next *Node, opts EdgeOptions) *Builder {
+	if next == nil {
+		b.g.fail("Then: nil node after %s", b.current())
+		return b
+	}
+	b.link("Then", next, opts)
+	b.tails = []endpoint{{node: next}}
+	return b
+}
+
+// Branch fan-out current step, every fn receives a Builder that starts at
+// current step, after Branch current step are the last steps of all branches
+func (b *Builder) Branch(fns ...func(b *Builder)) *Builder {
+	var tails []endpoint
+	for _, fn := range fns {
+		branch := &Builder{g: b.g, tails: append([]endpoint(nil), b.tails...)}
+		fn(branch)
+		tails = append(tails, branch.tails...)
+	}
+	b.tails = tails
+	return b
+}
+
+// Merge add nodes named refs to current step, so next step receives
+// messages from all of them (fan-in)
+func (b *Builder) Merge(refs ...string) *Builder {
+	for _, r := range refs {
+		b.tails = append(b.tails, endpoint{ref: r})
+	}
+	return b
+}
+
+// To chain current step to all sinks (fan-out), after To there is no current step
+func (b *Builder) To(sinks ...*Node) *Builder {
+	for _, s := range sinks {
+		if s == nil {
+			b.g.fail("To: nil node after %s", b.current())
+			continue
+		}
+		b.link("To", s, EdgeOptions{})
+	}
+	b.tails = nil
+	return b
+}
+
+func (b *Builder) link(step string, next *Node, opts EdgeOptions) {
+	b.g.add(next)
+	if len(b.tails) == 0 {
+		b.g.fail("%s: %s has no upstream, use From first", step, next.Name())
+		return
+	}
+	for _, t := range b.tails {
+		b.g.edges = append(b.g.edges, builderEdge{from: t, to: endpoint{node: next}, opts: opts})
+	}
+}
+
+func (b *Builder) current() string {
+	names := make([]string, len(b.tails))
+	for i, t := range b.tails {
+		names[i] = t.String()
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+// Build validate the graph, chain all nodes and return a Pipeliner,
+// graph must not have cycles and nodes must not be running
+func (b *Builder) Build() (Pipeliner, error) {
+	errs := append([]string(nil), b.g.errs...)
+	byName := make(map[string]*Node, len(b.g.nodes))
+	for _, n := range b.g.nodes {
+		if other, ok := byName[n.Name()]; ok && other != n {
+			errs = append(errs, fmt.Sprintf("duplicated node name '%s'", n.Name()))
+		}
+		byName[n.Name()] = n
+		if n.Running() {
+			errs = append(errs, fmt.Sprintf("node %s is already running", n.Name()))
+		}
+	}
+	resolve := func(e endpoint) *Node {
+		if e.node != nil {
+			return e.node
+		}
+		n, ok := byName[e.ref]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("Merge: unknown node '%s'", e.ref))
+		}
+		return n
+	}
+	type edge struct {
+		from, to *Node
+		opts     EdgeOptions
+	}
+	edges := make([]edge, 0, len(b.g.edges))
+	next := make(map[*Node][]*Node)
+	for _, e := range b.g.edges {
+		from, to := resolve(e.from), resolve(e.to)
+		if from == nil || to == nil {
+			continue
+		}
+		edges = append(edges, edge{from: from, to: to, opts: e.opts})
+		next[from] = append(next[from], to)
+	}
+	if len(b.g.nodes) == 0 {
+		errs = append(errs, "no nodes")
+	}
+	if c := findCycle(b.g.nodes, next); c != "" {
+		errs = append(errs, "cycle "+c)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGraph, strings.Join(errs, "; "))
+	}
+	for _, e := range edges {
+		e.from.ChainWith(e.to, e.opts)
+	}
+	return FreePipeline(b.g.nodes...), nil
+}
+
+// findCycle returns a path like a -> b -> a or an empty string
+func findCycle(nodes []*Node, next map[*Node][]*Node) string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Node]int, len(nodes))
+	var path []*Node
+	var visit func(n *Node) string
+	visit = func(n *Node) string {
+		state[n] = visiting
+		path = append(path, n)
+		for _, m := range next[n] {
+			switch state[m] {
+			case visiting:
+				var names []string
+				for i := len(path) - 1; i >= 0; i-- {
+					names = append([]string{path[i].Name()}, names...)
+					if path[i] == m {
+						break
+					}
+				}
+				return strings.Join(append(names, m.Name()), " -> ")
+			case 0:
+				if c := visit(m); c != "" {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+		return ""
+	}
+	for _, n := range nodes {
+		if state[n] == 0 {
+			if c := visit(n); c != "" {
+				return c
+			}
+		}
+	}
+	return ""
+}
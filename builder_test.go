@@ -0,0 +1,82 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestBuilder(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{values: []string{"a", "b"}})
+	other := selina.NewNode("other", &sliceReader{values: []string{"c"}})
+	left := selina.NewNode("left", &dummyWorker{})
+	right := selina.NewNode("right", &dummyWorker{})
+	w := &sliceWriter{}
+	sink := selina.NewNode("sink", w)
+	audit := &sliceWriter{}
+	p, err := selina.NewBuilder().
+		From(src).
+		Branch(
+			func(b *selina.Builder) { b.Then(left) },
+			func(b *selina.Builder) { b.Then(right).To(selina.NewNode("audit", audit)) },
+		).
+		Merge("other").
+		To(sink).
+		From(other).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() err = %v", err)
+	}
+	if len(p.Nodes()) != 6 {
+		t.Fatalf("Build() nodes = %d", len(p.Nodes()))
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	sort.Strings(w.values)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() sink = %v, want %v", w.values, want)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(audit.values, want) {
+		t.Fatalf("Run() audit = %v, want %v", audit.values, want)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	running := selina.NewNode("running", &lazyWorker{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = running.Start(ctx) }()
+	for !running.Running() {
+		time.Sleep(time.Millisecond)
+	}
+	a := selina.NewNode("a", &dummyWorker{})
+	b := selina.NewNode("b", &dummyWorker{})
+	tests := []struct {
+		name    string
+		builder *selina.Builder
+		want    string
+	}{
+		{name: "empty", builder: selina.NewBuilder(), want: "no nodes"},
+		{name: "nil node", builder: selina.NewBuilder().From(a).Then(nil), want: "Then: nil node after [a]"},
+		{name: "no upstream", builder: selina.NewBuilder().Then(a), want: "a has no upstream"},
+		{name: "unknown ref", builder: selina.NewBuilder().From(a).Merge("x").To(b), want: "unknown node 'x'"},
+		{name: "cycle", builder: selina.NewBuilder().From(a).Then(b).Then(a), want: "cycle a -> b -> a"},
+		{name: "duplicated", builder: selina.NewBuilder().From(a).To(selina.NewNode("a", &dummyWorker{})), want: "duplicated node name 'a'"},
+		{name: "running", builder: selina.NewBuilder().From(a).To(running), want: "running is already running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if !errors.Is(err, selina.ErrInvalidGraph) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Build() err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,25 +2,46 @@ package selina
 
 import (
 	"bytes"
-	"sync"
-
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var pool = sync.Pool{New: func() any {
 	return bytes.NewBuffer(nil)
 }}
 
+// MaxPoolBufferSize buffers with a bigger capacity are released to the garbage
+// collector instead of returned to the pool, so a single huge message does not
+// keep its memory forever
+var MaxPoolBufferSize = 1 << 20
+
 // GetBuffer returns a buffer from a pool of buffers
 func GetBuffer() *bytes.Buffer {
 	return pool.Get().(*bytes.Buffer)
 }
 
 // FreeBuffer calls Buffer.Reset and return buffer to the pool
+// if buffer is shared (see Retain) it is returned only when all owners free it,
+// a tracked buffer (see OnAck) that was not acknowledged is nacked with ErrNotAcked.
+// Every message received or created must be freed or sent exactly once, a buffer
+// that is only dropped keeps its acks, budget charge (see WithMemoryBudget)
+// and references forever, so sources may block on an exhausted budget
 func FreeBuffer(b *bytes.Buffer) {
 	if b == nil {
 		return
 	}
+	Ack(b, ErrNotAcked)
+	if !release(b) {
+		return
+	}
+	releaseBudget(b)
+	dropMarker(b)
+	if b.Cap() > MaxPoolBufferSize {
+		return
+	}
 	b.Reset()
 	pool.Put(b)
 }
@@ -28,9 +49,22 @@ func FreeBuffer(b *bytes.Buffer) {
 // Broadcaster allow to write same value to multiple groutines
 type Broadcaster struct {
 	DataCounter
+	// Shared when true all clients receive the same buffer instead of a copy
+	// it is released when every client calls FreeBuffer, so clients must not
+	// modify messages (see Own)
+	Shared  bool
 	out     []chan<- *bytes.Buffer
+	targets []string
 	mtx     sync.Mutex
 	running bool
+	// blocked is a client index + 1 when a send is blocked, zero otherwise
+	blocked      int32
+	blockedSince int64
+	// wm is the node watermark forwarded to clients, lastMark is the last one sent
+	wm       *watermark
+	lastMark int64
+	// quit abandons blocked sends, messages are freed instead of delivered
+	quit <-chan struct{}
 }
 
 // Broadcast read values from input and send it to output channels
@@ -38,24 +72,115 @@ func (b *Broadcaster) Broadcast(input <-chan *bytes.Buffer) {
 	b.mtx.Lock()
 	b.running = true
 	b.mtx.Unlock()
-	for in := range input {
-		for _, out := range b.out {
-			data := GetBuffer()
-			data.Write(in.Bytes())
-			//TODO: this can be a deadlock
+	last := len(b.out) - 1
+	var notify chan struct{}
+	if b.wm != nil {
+		notify = b.wm.notify
+	}
+	for {
+		var in *bytes.Buffer
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				b.close()
+				return
+			}
+			in = msg
+		case <-notify:
+			b.mark(atomic.LoadInt64(&b.wm.forwarded))
+			continue
+		}
+		if t, ok := markerTime(in); ok {
+			b.mark(t)
+			FreeBuffer(in)
+			continue
+		}
+		// tracked messages are always copied so every branch acks its own buffer
+		shared := b.Shared && !IsTracked(in)
+		if shared {
+			Retain(in, last)
+		}
+		for i, out := range b.out {
+			data := in
+			// last client (or the only one) always get the original buffer
+			if i != last && !shared {
+				data = GetBuffer()
+				data.Write(in.Bytes())
+				derive(in, data)
+				chargeCopy(in, data)
+			}
 			b.SumData(data.Bytes())
-			out <- data
+			b.send(i, out, data)
+		}
+		if last < 0 {
+			// there is nobody downstream, so message is completely processed
+			Ack(in, nil)
+			FreeBuffer(in)
 		}
-		FreeBuffer(in)
 	}
-	// close all channels when all data is readed
+}
+
+// close all channels when all data is readed
+func (b *Broadcaster) close() {
+	if b.wm != nil {
+		b.mark(atomic.LoadInt64(&b.wm.forwarded))
+	}
 	for _, c := range b.out {
 		close(c)
 	}
 }
 
+// mark send watermark t to every client if it is newer than last one
+func (b *Broadcaster) mark(t int64) {
+	if t <= b.lastMark {
+		return
+	}
+	b.lastMark = t
+	for i, out := range b.out {
+		b.send(i, out, newMarker(t))
+	}
+}
+
+// send deliver msg to client i, a slow or dead client blocks all others
+// so blocked sends are recorded for stall detection (see RunWithWatchdog),
+// once quit is closed a blocked send frees msg so a cancelled cycle can finish
+func (b *Broadcaster) send(i int, out chan<- *bytes.Buffer, msg *bytes.Buffer) {
+	select {
+	case out <- msg:
+		return
+	default:
+	}
+	atomic.StoreInt64(&b.blockedSince, time.Now().UnixNano())
+	atomic.StoreInt32(&b.blocked, int32(i+1))
+	select {
+	case out <- msg:
+	case <-b.quit:
+		FreeBuffer(msg)
+	}
+	atomic.StoreInt32(&b.blocked, 0)
+}
+
+// Blocked returns the target of a send that is blocked since a given time,
+// target is the id passed to ClientFor or an empty string
+func (b *Broadcaster) Blocked() (target string, since time.Time, ok bool) {
+	i := atomic.LoadInt32(&b.blocked)
+	if i == 0 {
+		return "", time.Time{}, false
+	}
+	since = time.Unix(0, atomic.LoadInt64(&b.blockedSince))
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.targets[i-1], since, true
+}
+
 // Client create an output chanel, it panics if Broadcast is already called
 func (b *Broadcaster) Client() <-chan *bytes.Buffer {
+	return b.ClientFor("")
+}
+
+// ClientFor create an output chanel for a given target id,
+// it panics if Broadcast is already called
+func (b *Broadcaster) ClientFor(target string) <-chan *bytes.Buffer {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
 	if b.running {
@@ -63,6 +188,7 @@ func (b *Broadcaster) Client() <-chan *bytes.Buffer {
 	}
 	c := make(chan *bytes.Buffer)
 	b.out = append(b.out, c)
+	b.targets = append(b.targets, target)
 	return c
 }
 
@@ -70,20 +196,109 @@ func (b *Broadcaster) Client() <-chan *bytes.Buffer {
 // this allow to add new channels after Receive is called
 type Receiver struct {
 	DataCounter
-	out chan *bytes.Buffer
-	wg  sync.WaitGroup
+	out   chan *bytes.Buffer
+	wg    sync.WaitGroup
+	edges []edge
 	// this is used to always initialize channel and allow to use receiver with default value
 	init sync.Once
+	// pending messages waiting to be read from output
+	pending      int32
+	pendingSince int64
+	prepared     bool
+	// marks is not nil when node tracks input watermarks
+	marks *inputMarks
+	// logs of durable edges
+	logs []*segmentLog
+	// quit abandons blocked deliveries, messages are freed instead
+	quit <-chan struct{}
+}
+
+type edge struct {
+	in   <-chan *bytes.Buffer
+	opts EdgeOptions
+}
+
+// prepare replace durable edges input with a reader of its log,
+// it is called by Node.Start so errors are reported instead of a panic in Receive
+func (r *Receiver) prepare() error {
+	if r.prepared {
+		return nil
+	}
+	r.prepared = true
+	for i, e := range r.edges {
+		if e.opts.Durable == nil {
+			continue
+		}
+		l, start, err := openLog(*e.opts.Durable)
+		if err != nil {
+			return fmt.Errorf("durable edge %s : %w", e.opts.Durable.Dir, err)
+		}
+		out := make(chan *bytes.Buffer)
+		l.quit = r.quit
+		go l.produce(e.in)
+		go l.consume(start, out)
+		r.edges[i].in = out
+		r.logs = append(r.logs, l)
+	}
+	return nil
+}
+
+// failure returns the first error of a durable edge log, a failed
+// log stops its edge so it is reported by Node.Start
+func (r *Receiver) failure() error {
+	for _, l := range r.logs {
+		if err := l.failure(); err != nil {
+			return fmt.Errorf("durable edge %s : %w", l.dir, err)
+		}
+	}
+	return nil
+}
+
+// deliver send msg to output recording blocked sends (see Pending)
+func (r *Receiver) deliver(msg *bytes.Buffer) {
+	r.SumData(msg.Bytes())
+	select {
+	case r.out <- msg:
+		r.delivered()
+		return
+	default:
+	}
+	if atomic.AddInt32(&r.pending, 1) == 1 {
+		atomic.StoreInt64(&r.pendingSince, time.Now().UnixNano())
+	}
+	select {
+	case r.out <- msg:
+		// other inputs still waiting are pending since this progress
+		if atomic.AddInt32(&r.pending, -1) > 0 {
+			atomic.StoreInt64(&r.pendingSince, time.Now().UnixNano())
+		}
+		r.delivered()
+	case <-r.quit:
+		atomic.AddInt32(&r.pending, -1)
+		FreeBuffer(msg)
+	}
 }
 
-func (r *Receiver) pipe(in <-chan *bytes.Buffer) {
+func (r *Receiver) pipe(i int, in <-chan *bytes.Buffer) {
 	for msg := range in {
-		r.SumData(msg.Bytes())
-		r.out <- msg
+		if r.watermark(i, msg) {
+			continue
+		}
+		r.deliver(msg)
 	}
+	r.inputClosed(i)
 	r.wg.Done()
 }
 
+// Pending returns true if there are messages waiting to be read from
+// Receive channel, since is an approximation when many inputs are blocked
+func (r *Receiver) Pending() (since time.Time, ok bool) {
+	if atomic.LoadInt32(&r.pending) == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(&r.pendingSince)), true
+}
+
 func (r *Receiver) initChan() {
 	r.init.Do(func() {
 		r.out = make(chan *bytes.Buffer)
@@ -95,11 +310,24 @@ func (r *Receiver) initChan() {
 // if there is no channels in watch list , this method returns
 // a nil channel
 func (r *Receiver) Receive() <-chan *bytes.Buffer {
+	if r.out == nil {
+		return nil
+	}
+	if err := r.prepare(); err != nil {
+		panic(err)
+	}
+	if r.prioritized() {
+		go r.schedule()
+		return r.out
+	}
+	r.wg.Add(len(r.edges))
+	for i, e := range r.edges {
+		go r.pipe(i, e.in)
+	}
 	go func() {
-		if r.out != nil {
-			r.wg.Wait()
-			close(r.out)
-		}
+		r.wg.Wait()
+		r.delivered()
+		close(r.out)
 	}()
 	return r.out
 }
@@ -107,14 +335,23 @@ func (r *Receiver) Receive() <-chan *bytes.Buffer {
 // Watch add a new channel to be joined
 // Call Watch after Receive is a panic
 func (r *Receiver) Watch(input <-chan *bytes.Buffer) {
+	r.WatchWith(input, EdgeOptions{})
+}
+
+// WatchWith add a new channel to be joined with given priority and weight
+// Call WatchWith after Receive is a panic
+func (r *Receiver) WatchWith(input <-chan *bytes.Buffer, opts EdgeOptions) {
 	r.initChan()
-	r.wg.Add(1)
-	go r.pipe(input)
+	r.edges = append(r.edges, edge{in: input, opts: opts})
 }
 
 // SendContext try to send msg to output, it returns an error if
 // context is canceled before msg is sent
 func SendContext(ctx context.Context, msg *bytes.Buffer, output chan<- *bytes.Buffer) error {
+	// sources wait here while memory budget is exhausted (see WithMemoryBudget)
+	if err := chargeBudget(ctx, msg); err != nil {
+		return err
+	}
 	select {
 	case output <- msg:
 		return nil
@@ -122,3 +359,59 @@ func SendContext(ctx context.Context, msg *bytes.Buffer, output chan<- *bytes.Bu
 		return ctx.Err()
 	}
 }
+
+// tap forward messages from in to out, each is called for every message
+// and when it returns false message is freed instead of forwarded.
+// closed is called when in is closed, it stops when quit is closed.
+// When wm is not nil its forwarded watermark is sent to out.
+// out is closed and returned channel is closed at the end
+func tap(in <-chan *bytes.Buffer, out chan<- *bytes.Buffer, quit <-chan struct{}, each func(*bytes.Buffer) bool, closed func(), wm *watermark) <-chan struct{} {
+	done := make(chan struct{})
+	var notify chan struct{}
+	if wm != nil {
+		notify = wm.notify
+	}
+	go func() {
+		defer close(done)
+		defer close(out)
+		for {
+			var msg *bytes.Buffer
+			select {
+			case m, ok := <-in:
+				if !ok {
+					if closed != nil {
+						closed()
+					}
+					if wm != nil && atomic.LoadInt64(&wm.forwarded) > 0 {
+						msg = newMarker(atomic.LoadInt64(&wm.forwarded))
+						select {
+						case out <- msg:
+						case <-quit:
+							FreeBuffer(msg)
+						}
+					}
+					return
+				}
+				msg = m
+			case <-notify:
+				msg = newMarker(atomic.LoadInt64(&wm.forwarded))
+			case <-quit:
+				return
+			}
+			// watermarks are not messages, interceptors never see them
+			if !isMarker(msg) && !each(msg) {
+				// dropped on purpose, it is not a failure
+				Ack(msg, nil)
+				FreeBuffer(msg)
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-quit:
+				FreeBuffer(msg)
+				return
+			}
+		}
+	}()
+	return done
+}
@@ -152,6 +152,74 @@ func BenchmarkBroadcaster(b *testing.B) {
 	}
 }
 
+func drainAndFree(in <-chan *bytes.Buffer, wg *sync.WaitGroup) {
+	for msg := range in {
+		selina.FreeBuffer(msg)
+	}
+	wg.Done()
+}
+
+// copyPerClient is Broadcaster.Broadcast before shared buffers, every client
+// gets a copy and input is freed, it is the baseline of BenchmarkBroadcasterFanOut
+func copyPerClient(input <-chan *bytes.Buffer, out []chan *bytes.Buffer) {
+	for in := range input {
+		for _, c := range out {
+			data := selina.GetBuffer()
+			data.Write(in.Bytes())
+			c <- data
+		}
+		selina.FreeBuffer(in)
+	}
+	for _, c := range out {
+		close(c)
+	}
+}
+
+// BenchmarkBroadcasterFanOut compare the original copy per client (baseline),
+// current default that gives original buffer to last client (copy) and
+// shared buffers (shared) with examples/performance message sizes
+func BenchmarkBroadcasterFanOut(b *testing.B) {
+	const clientCount = 4
+	sizes := []int{48, 4 * 1024, 64 * 1024}
+	for _, size := range sizes {
+		msg := bytes.Repeat([]byte("x"), size)
+		for _, mode := range []string{"baseline", "copy", "shared"} {
+			b.Run(fmt.Sprintf("%s_c(%d)_b(%d)", mode, clientCount, size), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(size))
+				broad := selina.Broadcaster{Shared: mode == "shared"}
+				var raw []chan *bytes.Buffer
+				wg := &sync.WaitGroup{}
+				for i := 0; i < clientCount; i++ {
+					wg.Add(1)
+					if mode == "baseline" {
+						c := make(chan *bytes.Buffer)
+						raw = append(raw, c)
+						go drainAndFree(c, wg)
+						continue
+					}
+					go drainAndFree(broad.Client(), wg)
+				}
+				input := make(chan *bytes.Buffer)
+				go func() {
+					for i := 0; i < b.N; i++ {
+						buff := selina.GetBuffer()
+						buff.Write(msg)
+						input <- buff
+					}
+					close(input)
+				}()
+				if mode == "baseline" {
+					copyPerClient(input, raw)
+				} else {
+					broad.Broadcast(input)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
 func BenchmarkReceiver(b *testing.B) {
 	// This function need to be improved to acquire more precise data
 	benchMarks := []struct {
@@ -0,0 +1,25 @@
+package selina
+
+import "time"
+
+// Clock abstract time source, so time based workers can be tested
+// without waiting real time
+type Clock interface {
+	// Now returns current time
+	Now() time.Time
+	// After waits for d to elapse and then sends current time on returned channel
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is a Clock backed by package time
+var SystemClock Clock = systemClock{}
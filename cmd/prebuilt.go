@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -15,14 +16,16 @@ import (
 	"github.com/licaonfee/selina/workers/random"
 	"github.com/licaonfee/selina/workers/remote"
 	"github.com/licaonfee/tserie"
-	"github.com/vmihailenco/msgpack"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/compress"
 	"github.com/licaonfee/selina/workers/csv"
+	"github.com/licaonfee/selina/workers/fixedwidth"
 	"github.com/licaonfee/selina/workers/ops"
 	"github.com/licaonfee/selina/workers/regex"
 	"github.com/licaonfee/selina/workers/sql"
 	"github.com/licaonfee/selina/workers/text"
+	"github.com/licaonfee/selina/workers/tsv"
 )
 
 var _ error = (*MakeError)(nil)
@@ -44,18 +47,104 @@ func newMakeError(f interface{}, err error) *MakeError {
 	return &MakeError{Facility: reflect.TypeOf(f).String(), err: err}
 }
 
-//GeneralOptions will not use jsonschema automatically because Type is determined in excution time
+// GeneralOptions will not use jsonschema automatically because Type is determined in excution time
 type GeneralOptions struct {
 	Name        string                 `yaml:"name"`
 	Type        string                 `yaml:"type"`
 	Args        map[string]interface{} `yaml:"args"`
 	ReadFormat  string                 `yaml:"read_format"`
 	WriteFormat string                 `yaml:"write_format"`
-	Fetch       []string               `yaml:"fetch"`
+	Fetch       []Fetch                `yaml:"fetch"`
+	OnError     *OnError               `yaml:"on_error"`
+}
+
+// Fetch is an upstream node, in YAML it is just a node name
+// or an object with node, priority, weight and durable
+type Fetch struct {
+	Node     string   `yaml:"node"`
+	Priority int      `yaml:"priority"`
+	Weight   int      `yaml:"weight"`
+	Durable  *Durable `yaml:"durable"`
+}
+
+// Durable store messages of an edge in dir (see selina.DurableOptions)
+type Durable struct {
+	Dir         string `yaml:"dir"`
+	SegmentSize int64  `yaml:"segment_size"`
+	Sync        bool   `yaml:"sync"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (f *Fetch) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*f = Fetch{Node: name}
+		return nil
+	}
+	type plain Fetch
+	return unmarshal((*plain)(f))
+}
+
+func (f Fetch) edge() selina.EdgeOptions {
+	opts := selina.EdgeOptions{Priority: f.Priority, Weight: f.Weight}
+	if f.Durable != nil {
+		opts.Durable = &selina.DurableOptions{Dir: f.Durable.Dir, SegmentSize: f.Durable.SegmentSize, Sync: f.Durable.Sync}
+	}
+	return opts
+}
+
+// OnError configure node error policy, Output is the node
+// that receives failed messages when Mode is route
+type OnError struct {
+	Mode    string        `yaml:"mode"`
+	Retries int           `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+	Output  string        `yaml:"output"`
+}
+
+func (o *OnError) policy() selina.ErrorPolicy {
+	return selina.ErrorPolicy{Mode: selina.ErrorMode(o.Mode), Retries: o.Retries, Backoff: o.Backoff}
 }
 
 type NewFacility func() NodeFacility
 
+// Formats resolve read_format and write_format through selina codec registry
+// an empty name means worker default codec
+type Formats struct {
+	ReadFormat  string `mapstructure:"read_format" json:"-"`
+	WriteFormat string `mapstructure:"write_format" json:"-"`
+}
+
+func (f Formats) unmarshaler() (selina.Unmarshaler, error) {
+	if f.ReadFormat == "" {
+		return nil, nil
+	}
+	c, err := selina.LookupCodec(f.ReadFormat)
+	if err != nil {
+		return nil, err
+	}
+	return c.Unmarshal, nil
+}
+
+func (f Formats) marshaler() (selina.Marshaler, error) {
+	if f.WriteFormat == "" {
+		return nil, nil
+	}
+	c, err := selina.LookupCodec(f.WriteFormat)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal, nil
+}
+
+// rawIsNil some workers use a nil codec to pass raw bytes
+func rawIsNil(name string) string {
+	if name == "raw" {
+		return ""
+	}
+	return name
+}
+
 type NodeFacility interface {
 	//Make create a selina.Worker, and wraps it in a selina.Node
 	Make(name string) (*selina.Node, error)
@@ -71,20 +160,19 @@ const (
 )
 
 func NewReadFile() NodeFacility {
-	return &ReadFile{SplitMode: splitLine}
+	return &ReadFile{SplitMode: splitLine, Compression: string(compress.Auto)}
 }
 
-//ReadFile read data from a text file
+// ReadFile read data from a text file
 type ReadFile struct {
-	Filename  string `mapstructure:"filename" json:"filename" jsonschema:"minLength=1"`
-	SplitMode string `mapstructure:"split" json:"split,omitempty" jsonschema:"enum=line,enum=byte,enum=char"`
+	Formats     `mapstructure:",squash"`
+	Filename    string `mapstructure:"filename" json:"filename" jsonschema:"minLength=1"`
+	SplitMode   string `mapstructure:"split" json:"split,omitempty" jsonschema:"enum=line,enum=byte,enum=char"`
+	Compression string `mapstructure:"compression" json:"compression,omitempty" jsonschema:"enum=auto,enum=none,enum=gzip,enum=zlib,enum=bzip2"`
+	Watermark   string `mapstructure:"watermark_field" json:"watermark_field,omitempty"`
 }
 
 func (r *ReadFile) Make(name string) (*selina.Node, error) {
-	f, err := os.Open(r.Filename)
-	if err != nil {
-		return nil, newMakeError(r, err)
-	}
 	var split bufio.SplitFunc
 	switch strings.ToLower(r.SplitMode) {
 	case splitLine:
@@ -96,8 +184,27 @@ func (r *ReadFile) Make(name string) (*selina.Node, error) {
 	default:
 		return nil, newMakeError(r, errors.New("invalid split mode "+r.SplitMode))
 	}
-	readOpts := text.ReaderOptions{Reader: f, SplitFunc: split, AutoClose: true}
+	formats := Formats{ReadFormat: rawIsNil(r.ReadFormat), WriteFormat: r.WriteFormat}
+	rf, err := formats.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(r, err)
+	}
+	wf, err := formats.marshaler()
+	if err != nil {
+		return nil, newMakeError(r, err)
+	}
+	comp := compress.Format(strings.ToLower(r.Compression))
+	if comp == compress.Auto && compress.FromFilename(r.Filename) != compress.None {
+		comp = compress.FromFilename(r.Filename)
+	}
+	// file is opened last so it is not leaked on invalid options
+	f, err := os.Open(r.Filename)
+	if err != nil {
+		return nil, newMakeError(r, err)
+	}
+	readOpts := text.ReaderOptions{Reader: f, SplitFunc: split, AutoClose: true, ReadFormat: rf, WriteFormat: wf, Compression: comp, WatermarkField: r.Watermark}
 	if err := readOpts.Check(); err != nil {
+		_ = f.Close()
 		return nil, newMakeError(r, err)
 	}
 	return selina.NewNode(name, text.NewReader(readOpts)), nil
@@ -111,10 +218,12 @@ func NewWriteFile() NodeFacility {
 }
 
 type WriteFile struct {
-	Filename   string      `mapstructure:"filename" json:"filename" jsonschema:"minLength=1"`
-	IfExists   string      `mapstructure:"ifexists" json:"ifexists,omitempty" jsonschema:"enum=fail,enum=overwrite,enum=append"`
-	Mode       os.FileMode `mapstructure:"mode" json:"mode,omitempty"` //0644
-	BufferSize int         `mapstructure:"buffer" json:"buffer,omitempty" jsonschema_extras:"minimum=0"`
+	Formats     `mapstructure:",squash"`
+	Filename    string      `mapstructure:"filename" json:"filename" jsonschema:"minLength=1"`
+	IfExists    string      `mapstructure:"ifexists" json:"ifexists,omitempty" jsonschema:"enum=fail,enum=overwrite,enum=append"`
+	Mode        os.FileMode `mapstructure:"mode" json:"mode,omitempty"` //0644
+	BufferSize  int         `mapstructure:"buffer" json:"buffer,omitempty" jsonschema_extras:"minimum=0"`
+	Compression string      `mapstructure:"compression" json:"compression,omitempty" jsonschema:"enum=none,enum=gzip,enum=zlib"`
 }
 
 func (w *WriteFile) Make(name string) (*selina.Node, error) {
@@ -132,27 +241,46 @@ func (w *WriteFile) Make(name string) (*selina.Node, error) {
 	if w.Mode == 0 {
 		w.Mode = 0600
 	}
-	var codec selina.Marshaler
-	switch "raw" {
-	case "json":
-		codec = json.Marshal
-	case "msgpack":
-		codec = msgpack.Marshal
-	case "raw", "":
-		codec = nil
-	default:
-		return nil, newMakeError(w, errors.New("invalid codec"))
+	formats := Formats{ReadFormat: rawIsNil(w.ReadFormat), WriteFormat: rawIsNil(w.WriteFormat)}
+	rf, err := formats.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(w, err)
+	}
+	codec, err := formats.marshaler()
+	if err != nil {
+		return nil, newMakeError(w, err)
+	}
+	comp := compress.Format(strings.ToLower(w.Compression))
+	if err := comp.CheckWrite(); err != nil {
+		return nil, newMakeError(w, err)
 	}
 	f, err := os.OpenFile(w.Filename, flags, w.Mode)
 	if err != nil {
 		return nil, newMakeError(w, err)
 	}
 
-	opts := text.WriterOptions{Writer: f, AutoClose: true, BufferSize: w.BufferSize, Codec: codec}
+	opts := text.WriterOptions{Writer: f, AutoClose: true, BufferSize: w.BufferSize, ReadFormat: rf, Codec: codec, Compression: comp}
 	if err := opts.Check(); err != nil {
+		_ = f.Close()
 		return nil, newMakeError(w, err)
 	}
-	return selina.NewNode(name, text.NewWriter(opts)), nil
+	return selina.NewNode(name, &fileWriter{Writer: text.NewWriter(opts), ifExists: strings.ToLower(w.IfExists)}), nil
+}
+
+// fileWriter export how write_file opened its file
+type fileWriter struct {
+	*text.Writer
+	ifExists string
+}
+
+// Describe implements selina.Describer interface
+func (w *fileWriter) Describe() (string, map[string]interface{}, error) {
+	typ, args, err := w.Writer.Describe()
+	if err != nil {
+		return "", nil, err
+	}
+	args["ifexists"] = w.ifExists
+	return typ, args, nil
 }
 
 var _ (NodeFacility) = (*SQLQuery)(nil)
@@ -162,15 +290,23 @@ func NewSQLQuery() NodeFacility {
 }
 
 type SQLQuery struct {
-	Driver string `mapstructure:"driver" json:"driver" jsonschema:"enum=mysql,enum=postgres,enum=clickhouse"`
-	DSN    string `mapstructure:"dsn" json:"dsn" jsonschema:"minLength=1"`
-	Query  string `mapstrcuture:"query" json:"query" jsonschema:"minLength=1"`
+	Formats   `mapstructure:",squash"`
+	Driver    string `mapstructure:"driver" json:"driver" jsonschema:"enum=mysql,enum=postgres,enum=clickhouse"`
+	DSN       string `mapstructure:"dsn" json:"dsn" jsonschema:"minLength=1"`
+	Query     string `mapstrcuture:"query" json:"query" jsonschema:"minLength=1"`
+	Watermark string `mapstructure:"watermark_column" json:"watermark_column,omitempty"`
 }
 
 func (s *SQLQuery) Make(name string) (*selina.Node, error) {
+	wf, err := s.marshaler()
+	if err != nil {
+		return nil, newMakeError(s, err)
+	}
 	opts := sql.ReaderOptions{Driver: s.Driver,
-		ConnStr: s.DSN,
-		Query:   s.Query}
+		ConnStr:         s.DSN,
+		Query:           s.Query,
+		WriteFormat:     wf,
+		WatermarkColumn: s.Watermark}
 	if err := opts.Check(); err != nil {
 		return nil, newMakeError(s, err)
 	}
@@ -184,16 +320,22 @@ func NewSQLInsert() NodeFacility {
 }
 
 type SQLInsert struct {
-	Driver string `mapstructure:"driver" json:"driver" jsonschema:"enum=mysql,enum=postgres,enum=clickhouse"`
-	DSN    string `mapstructure:"dsn" json:"dsn" jsonschema:"minLength=1"`
-	Table  string `mapstructure:"table" json:"table" jsonschema:"minLength=1"`
+	Formats `mapstructure:",squash"`
+	Driver  string `mapstructure:"driver" json:"driver" jsonschema:"enum=mysql,enum=postgres,enum=clickhouse"`
+	DSN     string `mapstructure:"dsn" json:"dsn" jsonschema:"minLength=1"`
+	Table   string `mapstructure:"table" json:"table" jsonschema:"minLength=1"`
 }
 
 func (s *SQLInsert) Make(name string) (*selina.Node, error) {
+	rf, err := s.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(s, err)
+	}
 	opts := sql.WriterOptions{
-		Driver:  s.Driver,
-		ConnStr: s.DSN,
-		Table:   s.Table,
+		Driver:     s.Driver,
+		ConnStr:    s.DSN,
+		Table:      s.Table,
+		ReadFormat: rf,
 	}
 	if err := opts.Check(); err != nil {
 		return nil, newMakeError(s, err)
@@ -226,21 +368,61 @@ func NewCSV() NodeFacility {
 }
 
 type CSV struct {
-	Mode    string   `mapstructure:"mode" json:"mode" jsonschema:"enum=decode,enum=encode"`
-	Header  []string `mapstructure:"header" json:"header,omitempty"`
-	Comma   rune     `mapstructure:"comma" json:"comma,omitempty" jsonschema:"minLegth=1,maxLength=1"`
-	UseCrlf bool     `mapstructure:"crlf" json:"crlf,omitempty" jsonschema:"minLegth=1,maxLength=1"`
-	Comment rune     `mapstructure:"comment" json:"comment,omitempty" jsonschema:"minLegth=1,maxLength=1"`
+	Formats    `mapstructure:",squash"`
+	Mode       string   `mapstructure:"mode" json:"mode" jsonschema:"enum=decode,enum=encode"`
+	Header     []string `mapstructure:"header" json:"header,omitempty"`
+	Comma      rune     `mapstructure:"comma" json:"comma,omitempty" jsonschema:"minLegth=1,maxLength=1"`
+	UseCrlf    bool     `mapstructure:"crlf" json:"crlf,omitempty" jsonschema:"minLegth=1,maxLength=1"`
+	Comment    rune     `mapstructure:"comment" json:"comment,omitempty" jsonschema:"minLegth=1,maxLength=1"`
+	Null       string   `mapstructure:"null_text" json:"null_text,omitempty"`
+	True       string   `mapstructure:"true_text" json:"true_text,omitempty"`
+	False      string   `mapstructure:"false_text" json:"false_text,omitempty"`
+	Precision  int      `mapstructure:"float_precision" json:"float_precision,omitempty" jsonschema_extras:"minimum=0"`
+	TimeLayout string   `mapstructure:"time_layout" json:"time_layout,omitempty"`
+	// encode only
+	Unknown     string `mapstructure:"unknown_fields" json:"unknown_fields,omitempty" jsonschema:"enum=ignore,enum=strict,enum=spill"`
+	SpillColumn string `mapstructure:"spill_column" json:"spill_column,omitempty"`
+	NoHeader    bool   `mapstructure:"no_header" json:"no_header,omitempty"`
+	// decode only
+	HeaderFromFirst bool              `mapstructure:"header_from_first" json:"header_from_first,omitempty"`
+	Ragged          string            `mapstructure:"ragged" json:"ragged,omitempty" jsonschema:"enum=truncate,enum=pad,enum=error"`
+	InferTypes      bool              `mapstructure:"infer_types" json:"infer_types,omitempty"`
+	Types           map[string]string `mapstructure:"types" json:"types,omitempty"`
+	MultiLine       bool              `mapstructure:"multi_line" json:"multi_line,omitempty"`
+	LazyQuotes      bool              `mapstructure:"lazy_quotes" json:"lazy_quotes,omitempty"`
 }
 
 func (c *CSV) Make(name string) (*selina.Node, error) {
 	var w selina.Worker
+	rf, err := c.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(c, err)
+	}
+	wf, err := c.marshaler()
+	if err != nil {
+		return nil, newMakeError(c, err)
+	}
 	switch c.Mode {
 	case "decode":
-		opts := csv.DecoderOptions{Header: c.Header, Comma: c.Comma, Comment: c.Comment}
+		opts := csv.DecoderOptions{Header: c.Header, Comma: c.Comma, Comment: c.Comment, Codec: wf,
+			HeaderFromFirst: c.HeaderFromFirst, Ragged: csv.Ragged(c.Ragged), InferTypes: c.InferTypes, MultiLine: c.MultiLine, LazyQuotes: c.LazyQuotes}
+		if len(c.Types) > 0 {
+			opts.Types = make(map[string]csv.ColumnType, len(c.Types))
+			for col, t := range c.Types {
+				opts.Types[col] = csv.ColumnType(t)
+			}
+		}
+		if err := opts.Check(); err != nil {
+			return nil, newMakeError(c, err)
+		}
 		w = csv.NewDecoder(opts)
 	case "encode":
-		opts := csv.EncoderOptions{Header: c.Header, Comma: c.Comma, UseCRLF: c.UseCrlf}
+		opts := csv.EncoderOptions{Header: c.Header, Comma: c.Comma, UseCRLF: c.UseCrlf, ReadFormat: rf,
+			Null: c.Null, True: c.True, False: c.False, FloatPrecision: c.Precision, TimeLayout: c.TimeLayout,
+			Unknown: csv.Unknown(c.Unknown), SpillColumn: c.SpillColumn, NoHeader: c.NoHeader}
+		if err := opts.Check(); err != nil {
+			return nil, newMakeError(c, err)
+		}
 		w = csv.NewEncoder(opts)
 	default:
 		return nil, newMakeError(c, errors.New("invalid mode value "+c.Mode))
@@ -248,6 +430,121 @@ func (c *CSV) Make(name string) (*selina.Node, error) {
 	return selina.NewNode(name, w), nil
 }
 
+var _ (NodeFacility) = (*TSV)(nil)
+
+func NewTSV() NodeFacility {
+	return &TSV{}
+}
+
+type TSV struct {
+	Formats  `mapstructure:",squash"`
+	Mode     string   `mapstructure:"mode" json:"mode" jsonschema:"enum=decode,enum=encode"`
+	Header   []string `mapstructure:"header" json:"header,omitempty"`
+	Null     string   `mapstructure:"null_text" json:"null_text,omitempty"`
+	NoHeader bool     `mapstructure:"no_header" json:"no_header,omitempty"`
+	// decode only
+	HeaderFromFirst bool              `mapstructure:"header_from_first" json:"header_from_first,omitempty"`
+	Types           map[string]string `mapstructure:"types" json:"types,omitempty"`
+}
+
+func (c *TSV) Make(name string) (*selina.Node, error) {
+	var w selina.Worker
+	rf, err := c.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(c, err)
+	}
+	wf, err := c.marshaler()
+	if err != nil {
+		return nil, newMakeError(c, err)
+	}
+	switch c.Mode {
+	case "decode":
+		opts := tsv.DecoderOptions{Header: c.Header, HeaderFromFirst: c.HeaderFromFirst, Null: c.Null, Codec: wf}
+		if len(c.Types) > 0 {
+			opts.Types = make(map[string]csv.ColumnType, len(c.Types))
+			for col, t := range c.Types {
+				opts.Types[col] = csv.ColumnType(t)
+			}
+		}
+		if err := opts.Check(); err != nil {
+			return nil, newMakeError(c, err)
+		}
+		w = tsv.NewDecoder(opts)
+	case "encode":
+		opts := tsv.EncoderOptions{Header: c.Header, NoHeader: c.NoHeader, Null: c.Null, ReadFormat: rf}
+		if err := opts.Check(); err != nil {
+			return nil, newMakeError(c, err)
+		}
+		w = tsv.NewEncoder(opts)
+	default:
+		return nil, newMakeError(c, errors.New("invalid mode value "+c.Mode))
+	}
+	return selina.NewNode(name, w), nil
+}
+
+var _ (NodeFacility) = (*FixedWidth)(nil)
+
+func NewFixedWidth() NodeFacility {
+	return &FixedWidth{}
+}
+
+type FixedColumn struct {
+	Name   string `mapstructure:"name" json:"name" jsonschema:"minLength=1"`
+	Offset int    `mapstructure:"offset" json:"offset" jsonschema_extras:"minimum=0"`
+	Width  int    `mapstructure:"width" json:"width" jsonschema_extras:"minimum=1"`
+	Align  string `mapstructure:"align" json:"align,omitempty" jsonschema:"enum=left,enum=right"`
+	Pad    string `mapstructure:"pad" json:"pad,omitempty" jsonschema:"minLength=1,maxLength=1"`
+	Type   string `mapstructure:"type" json:"type,omitempty" jsonschema:"enum=string,enum=int,enum=float,enum=bool"`
+}
+
+type FixedWidth struct {
+	Formats  `mapstructure:",squash"`
+	Mode     string        `mapstructure:"mode" json:"mode" jsonschema:"enum=decode,enum=encode"`
+	Columns  []FixedColumn `mapstructure:"columns" json:"columns" jsonschema:"minItems=1"`
+	Truncate bool          `mapstructure:"truncate" json:"truncate,omitempty"`
+}
+
+func (f *FixedWidth) layout() (fixedwidth.Layout, error) {
+	l := make(fixedwidth.Layout, len(f.Columns))
+	for i, c := range f.Columns {
+		l[i] = fixedwidth.Column{Name: c.Name, Offset: c.Offset, Width: c.Width,
+			Align: fixedwidth.Align(c.Align), Type: csv.ColumnType(c.Type)}
+		if c.Pad != "" {
+			pad := []rune(c.Pad)
+			if len(pad) != 1 {
+				return nil, fmt.Errorf("column %s: pad must be a single character", c.Name)
+			}
+			l[i].Pad = pad[0]
+		}
+	}
+	return l, l.Check()
+}
+
+func (f *FixedWidth) Make(name string) (*selina.Node, error) {
+	var w selina.Worker
+	rf, err := f.unmarshaler()
+	if err != nil {
+		return nil, newMakeError(f, err)
+	}
+	wf, err := f.marshaler()
+	if err != nil {
+		return nil, newMakeError(f, err)
+	}
+	columns, err := f.layout()
+	if err != nil {
+		return nil, newMakeError(f, err)
+	}
+	switch f.Mode {
+	case "decode":
+		w = fixedwidth.NewDecoder(fixedwidth.DecoderOptions{Columns: columns, Codec: wf})
+	case "encode":
+		w = fixedwidth.NewEncoder(fixedwidth.EncoderOptions{Columns: columns, ReadFormat: rf, Truncate: f.Truncate})
+	default:
+		return nil, newMakeError(f, errors.New("invalid mode value "+f.Mode))
+	}
+	return selina.NewNode(name, w), nil
+}
+
 var _ NodeFacility = (*Cron)(nil)
 
 func NewCron() NodeFacility {
@@ -277,6 +574,7 @@ type Remote struct {
 	Mode       string `mapstructure:"mode" json:"mode"  jsonschema:"enum=client,enum=server"`
 	Address    string `mapstructure:"address" json:"address"`
 	BufferSize int    `mapstructure:"buffer" json:"buffer,omitempty"`
+	WaitAck    bool   `mapstructure:"wait_ack" json:"wait_ack,omitempty"`
 }
 
 var allowedSchemes = []string{"tcp", "tcp4", "tcp6", "unix", "unixpacket"}
@@ -296,7 +594,7 @@ func (r *Remote) Make(name string) (*selina.Node, error) {
 	case "client":
 		w = remote.NewClient(remote.ClientOptions{Address: r.Address})
 	case "server":
-		w = remote.NewServer(remote.ServerOptions{Network: u.Scheme, Address: u.Host, BufferSize: r.BufferSize})
+		w = remote.NewServer(remote.ServerOptions{Network: u.Scheme, Address: u.Host, BufferSize: r.BufferSize, WaitAck: r.WaitAck})
 	default:
 		return nil, newMakeError(r, errors.New("invalid mode value "+r.Mode))
 	}
@@ -323,13 +621,22 @@ func NewRandom() NodeFacility {
 var _ NodeFacility = (*TimeSerie)(nil)
 
 type TimeSerie struct {
-	Start  string `mapstructure:"start" json:"start"`
-	Stop   string `mapstructure:"stop" json:"stop"`
-	Format string `mapstrcuture:"format" json:"format"`
-	Step   string `mapstructure:"step" json:"step"`
+	Formats    `mapstructure:",squash"`
+	Start      string `mapstructure:"start" json:"start"`
+	Stop       string `mapstructure:"stop" json:"stop"`
+	Format     string `mapstrcuture:"format" json:"format"`
+	Step       string `mapstructure:"step" json:"step"`
+	Watermarks bool   `mapstructure:"watermarks" json:"watermarks,omitempty"`
 }
 
 func (t *TimeSerie) Make(name string) (*selina.Node, error) {
+	wf, err := t.marshaler()
+	if err != nil {
+		return nil, newMakeError(t, err)
+	}
+	if wf == nil {
+		wf = json.Marshal
+	}
 	d, err := time.ParseDuration(t.Step)
 	if err != nil {
 		return nil, fmt.Errorf("step %w", err)
@@ -347,7 +654,8 @@ func (t *TimeSerie) Make(name string) (*selina.Node, error) {
 		Stop:        stop,
 		Step:        d,
 		Generator:   tserie.Normal(1, 0),
-		WriteFormat: json.Marshal,
+		WriteFormat: wf,
+		Watermarks:  t.Watermarks,
 	}
 	w := ops.NewTimeSerie(opts)
 	return selina.NewNode(name, w), nil
@@ -358,3 +666,84 @@ func NewTimeSerie() NodeFacility {
 		Format: time.RFC3339,
 	}
 }
+
+var _ NodeFacility = (*Pipeline)(nil)
+
+// includer is implemented by facilities that load other definition files,
+// files are the definitions being loaded, last one includes the facility
+type includer interface {
+	includedBy(files []string)
+}
+
+// Pipeline run other definition file as a single node, a relative File
+// is resolved from the directory of the definition that includes it
+type Pipeline struct {
+	File    string `mapstructure:"file" json:"file"`
+	Entry   string `mapstructure:"entry" json:"entry"`
+	Exit    string `mapstructure:"exit" json:"exit"`
+	parents []string
+}
+
+func (p *Pipeline) includedBy(files []string) {
+	p.parents = files
+}
+
+// path returns absolute path of File
+func (p *Pipeline) path() (string, error) {
+	name := p.File
+	if len(p.parents) > 0 && !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(p.parents[len(p.parents)-1]), name)
+	}
+	return filepath.Abs(name)
+}
+
+// build load File into a new pipeline, it is called again when node is restarted
+func (p *Pipeline) build() (selina.Pipeliner, *selina.Node, *selina.Node, error) {
+	name, err := p.path()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, parent := range p.parents {
+		if parent == name {
+			return nil, nil, nil, fmt.Errorf("%s includes itself", p.File)
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+	def, err := loadDefinition(f, facilities, append(p.parents[:len(p.parents):len(p.parents)], name))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s %w", p.File, err)
+	}
+	inner, err := createPipeline(def)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s %w", p.File, err)
+	}
+	var entry, exit *selina.Node
+	for _, n := range def.nodes {
+		if n.Name() == p.Entry {
+			entry = n
+		}
+		if n.Name() == p.Exit {
+			exit = n
+		}
+	}
+	if entry == nil || exit == nil {
+		return nil, nil, nil, fmt.Errorf("%w: entry '%s' exit '%s'", selina.ErrNilEntryExit, p.Entry, p.Exit)
+	}
+	return inner, entry, exit, nil
+}
+
+func (p *Pipeline) Make(name string) (*selina.Node, error) {
+	w, err := selina.NewPipelineWorkerBuilder(p.build)
+	if err != nil {
+		return nil, newMakeError(p, err)
+	}
+	return selina.NewNode(name, w), nil
+}
+
+func NewPipeline() NodeFacility {
+	return &Pipeline{}
+}
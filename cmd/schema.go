@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/alecthomas/jsonschema"
+	"github.com/licaonfee/selina"
 )
 
 type nodeIf struct {
@@ -25,11 +26,26 @@ type nodeIf struct {
 	} `json:"then"`
 }
 
+// errorModeIf require field with given constraints when on_error mode is m
+func errorModeIf(m selina.ErrorMode, field string, constraints map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"if": map[string]interface{}{
+			"required":   []string{"mode"},
+			"properties": map[string]interface{}{"mode": map[string]interface{}{"const": m}},
+		},
+		"then": map[string]interface{}{
+			"required":   []string{field},
+			"properties": map[string]interface{}{field: constraints},
+		},
+	}
+}
+
 func schema(availableNodes map[string]NewFacility) string {
 	ifList := make([]nodeIf, len(availableNodes))
 	keys := make([]string, len(availableNodes))
 	sc := map[string]interface{}{
-		"$schema":     "http://json-schema.org/draft-04/schema#",
+		// if/then of node args and on_error need draft-07
+		"$schema":     "http://json-schema.org/draft-07/schema#",
 		"definitions": nil,
 		"type":        "object",
 		"properties": map[string]interface{}{
@@ -48,11 +64,64 @@ func schema(availableNodes map[string]NewFacility) string {
 							"type": "string",
 							"enum": keys,
 						},
+						"read_format": map[string]interface{}{
+							"type": "string",
+							"enum": selina.Codecs(),
+						},
+						"write_format": map[string]interface{}{
+							"type": "string",
+							"enum": selina.Codecs(),
+						},
+						"on_error": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"mode": map[string]interface{}{
+									"type": "string",
+									"enum": []selina.ErrorMode{selina.ErrorFail, selina.ErrorSkip, selina.ErrorRetry, selina.ErrorRoute},
+								},
+								"retries": map[string]interface{}{"type": "integer", "minimum": 0},
+								"backoff": map[string]interface{}{"type": "string"},
+								"output": map[string]interface{}{
+									"type":    "string",
+									"pattern": "^[a-zA-Z]+[a-zA-Z0-9_]*$",
+								},
+							},
+							// same rules as ErrorPolicy.Check and route mode in layout
+							"allOf": []interface{}{
+								errorModeIf(selina.ErrorRetry, "retries", map[string]interface{}{"minimum": 1}),
+								errorModeIf(selina.ErrorRoute, "output", map[string]interface{}{"minLength": 1}),
+							},
+						},
 						"fetch": map[string]interface{}{
 							"type": "array",
 							"items": map[string]interface{}{
-								"type":    "string",
-								"pattern": "^[a-zA-Z]+[a-zA-Z0-9_]*$",
+								"oneOf": []interface{}{
+									map[string]interface{}{
+										"type":    "string",
+										"pattern": "^[a-zA-Z]+[a-zA-Z0-9_]*$",
+									},
+									map[string]interface{}{
+										"type":     "object",
+										"required": []string{"node"},
+										"properties": map[string]interface{}{
+											"node": map[string]interface{}{
+												"type":    "string",
+												"pattern": "^[a-zA-Z]+[a-zA-Z0-9_]*$",
+											},
+											"priority": map[string]interface{}{"type": "integer"},
+											"weight":   map[string]interface{}{"type": "integer", "minimum": 1},
+											"durable": map[string]interface{}{
+												"type":     "object",
+												"required": []string{"dir"},
+												"properties": map[string]interface{}{
+													"dir":          map[string]interface{}{"type": "string", "minLength": 1},
+													"segment_size": map[string]interface{}{"type": "integer", "minimum": 0},
+													"sync":         map[string]interface{}{"type": "boolean"},
+												},
+											},
+										},
+									},
+								},
 							},
 						},
 					},
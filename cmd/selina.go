@@ -7,9 +7,10 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -32,18 +33,48 @@ func layout(def *PipeDefinition) (selina.Pipeliner, error) {
 			usefetch = true
 		}
 	}
+	chained := make(map[string]struct{})
+	errOutputs := make(map[string]struct{})
+	for _, d := range def.NodeDefs {
+		if d.OnError == nil {
+			continue
+		}
+		if d.OnError.Output == "" {
+			if selina.ErrorMode(d.OnError.Mode) == selina.ErrorRoute {
+				return nil, fmt.Errorf("node %s : route mode without error output", d.Name)
+			}
+			continue
+		}
+		next, ok := nodes[d.OnError.Output]
+		if !ok {
+			return nil, fmt.Errorf("missing error output node '%s'", d.OnError.Output)
+		}
+		nodes[d.Name].ChainError(next)
+		chained[next.Name()] = struct{}{}
+		errOutputs[next.Name()] = struct{}{}
+	}
 	if !usefetch {
-		return selina.LinealPipeline(def.nodes...), nil
+		// error outputs are not part of the lineal chain
+		var prev *selina.Node
+		for _, n := range def.nodes {
+			if _, ok := errOutputs[n.Name()]; ok {
+				continue
+			}
+			if prev != nil {
+				prev.Chain(n)
+			}
+			prev = n
+		}
+		return selina.FreePipeline(def.nodes...), nil
 	}
-	chained := make(map[string]struct{})
 	for _, d := range def.NodeDefs {
 		me := nodes[d.Name]
 		for _, f := range d.Fetch {
-			prev, ok := nodes[f]
+			prev, ok := nodes[f.Node]
 			if !ok {
 				return nil, errors.New("missing node")
 			}
-			prev.Chain(me)
+			prev.ChainWith(me, f.edge())
 			chained[me.Name()] = struct{}{}
 			chained[prev.Name()] = struct{}{}
 		}
@@ -58,7 +89,9 @@ func layout(def *PipeDefinition) (selina.Pipeliner, error) {
 	return selina.FreePipeline(def.nodes...), nil
 }
 
-func loadDefinition(definition io.Reader, availableNodes map[string]NewFacility) (*PipeDefinition, error) {
+// loadDefinition create nodes of definition, files are the definitions being
+// loaded (last one is definition) so included definitions can be resolved
+func loadDefinition(definition io.Reader, availableNodes map[string]NewFacility, files []string) (*PipeDefinition, error) {
 	dec := yaml.NewDecoder(definition)
 	dec.SetStrict(true)
 	var defined PipeDefinition
@@ -73,22 +106,47 @@ func loadDefinition(definition io.Reader, availableNodes map[string]NewFacility)
 			return nil, errors.New("unavailable type")
 		}
 		facility := facFunc()
+		if err := checkFormats(n); err != nil {
+			return nil, err
+		}
+		if n.Args == nil {
+			n.Args = make(map[string]interface{})
+		}
 		n.Args["read_format"] = n.ReadFormat
 		n.Args["write_format"] = n.WriteFormat
 		if err := mapstructure.Decode(n.Args, &facility); err != nil {
-			fmt.Printf("%v\n", n.Args)
 			return nil, fmt.Errorf("decode struct %w", err)
 		}
+		if inc, ok := facility.(includer); ok {
+			inc.includedBy(files)
+		}
 		node, err := facility.Make(n.Name)
 		if err != nil {
 			return nil, err
 		}
+		if n.OnError != nil {
+			if err := node.SetErrorPolicy(n.OnError.policy()); err != nil {
+				return nil, fmt.Errorf("node %s : %w", n.Name, err)
+			}
+		}
 		pipeNodes = append(pipeNodes, node)
 	}
 	defined.nodes = pipeNodes
 	return &defined, nil
 }
 
+func checkFormats(n GeneralOptions) error {
+	for _, name := range []string{n.ReadFormat, n.WriteFormat} {
+		if name == "" {
+			continue
+		}
+		if _, err := selina.LookupCodec(name); err != nil {
+			return fmt.Errorf("node %s : %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
 func createPipeline(defined *PipeDefinition) (selina.Pipeliner, error) {
 	p, err := layout(defined)
 	if err != nil {
@@ -97,51 +155,87 @@ func createPipeline(defined *PipeDefinition) (selina.Pipeliner, error) {
 	return p, nil
 }
 
+// facilities are all node types available in definition files
+var facilities map[string]NewFacility
+
+func init() {
+	// pipeline facility use this map so it can not be initialized in declaration
+	facilities = map[string]NewFacility{
+		"read_file":   NewReadFile,
+		"write_file":  NewWriteFile,
+		"sql_query":   NewSQLQuery,
+		"sql_insert":  NewSQLInsert,
+		"regex":       NewRegexp,
+		"csv":         NewCSV,
+		"tsv":         NewTSV,
+		"fixed_width": NewFixedWidth,
+		"cron":        NewCron,
+		"remote":      NewRemote,
+		"random":      NewRandom,
+		"time_serie":  NewTimeSerie,
+		"pipeline":    NewPipeline,
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
 func main() {
 	filename := flag.String("file", "", "pipeline definition file use - to stdin ")
 	timeout := flag.Duration("timeout", time.Duration(0), "maximum time to run, default limitless")
 	printSchema := flag.Bool("schema", false, "print jsonschema for yaml LSP")
 	graph := flag.Bool("graph", false, "print graphviz insteadof executing")
-	log.SetFlags(log.Llongfile | log.LstdFlags)
+	logLevel := flag.String("log-level", "info", "log level one of debug, info, warn, error")
+	logJSON := flag.Bool("log-json", false, "write logs as json instead of text")
+	stall := flag.Duration("stall-timeout", time.Duration(0), "abort when a node is blocked this time, default disabled")
+	memory := flag.Int64("memory-budget", 0, "max bytes of messages in flight, default limitless")
 	flag.Parse()
-	var availableNodes = map[string]NewFacility{
-		"read_file":  NewReadFile,
-		"write_file": NewWriteFile,
-		"sql_query":  NewSQLQuery,
-		"sql_insert": NewSQLInsert,
-		"regex":      NewRegexp,
-		"csv":        NewCSV,
-		"cron":       NewCron,
-		"remote":     NewRemote,
-		"random":     NewRandom,
-		"time_serie": NewTimeSerie,
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log level %s\n", *logLevel)
+		os.Exit(2)
+	}
+	hopts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, hopts)
+	if *logJSON {
+		handler = slog.NewJSONHandler(os.Stderr, hopts)
 	}
+	logger := slog.New(handler)
 	if *printSchema {
-		fmt.Println(schema(availableNodes))
+		fmt.Println(schema(facilities))
 		return
 	}
 
 	if *filename == "" {
-		log.Fatal("file is mandatory")
+		fatal(logger, "file is mandatory")
 	}
 
 	var fileStream io.Reader = os.Stdin
+	// definitions included from stdin are relative to working directory
+	var files []string
 
 	if *filename != "-" {
 		data, err := os.ReadFile(*filename)
 		if err != nil {
-			log.Fatal(err)
+			fatal(logger, "reading definition", "file", *filename, "error", err)
 		}
 		fileStream = bytes.NewBuffer(data)
+		name, err := filepath.Abs(*filename)
+		if err != nil {
+			fatal(logger, "reading definition", "file", *filename, "error", err)
+		}
+		files = []string{name}
 	}
 
-	def, err := loadDefinition(fileStream, availableNodes)
+	def, err := loadDefinition(fileStream, facilities, files)
 	if err != nil {
-		log.Fatal(err)
+		fatal(logger, "loading definition", "error", err)
 	}
 	p, err := createPipeline(def)
 	if err != nil {
-		log.Fatal(err)
+		fatal(logger, "creating pipeline", "error", err)
 	}
 	if *graph {
 		selina.Graph(p, os.Stdout)
@@ -160,13 +254,25 @@ func main() {
 		<-s
 		cancel()
 	}()
-	err = p.Run(ctx)
+	ctx = selina.WithLogger(ctx, selina.NewSlogLogger(logger))
+	if *memory > 0 {
+		budget, err := selina.NewBudget(*memory)
+		if err != nil {
+			fatal(logger, "invalid memory budget", "error", err)
+		}
+		ctx = selina.WithMemoryBudget(ctx, budget)
+	}
+	if *stall > 0 {
+		err = selina.RunWithWatchdog(ctx, p, selina.WatchdogOptions{Timeout: *stall, Cancel: true})
+	} else {
+		err = p.Run(ctx)
+	}
 	switch {
 	case err == nil:
 	case errors.Is(err, context.DeadlineExceeded):
-		log.Fatalf("timeout after %v", *timeout)
+		fatal(logger, "timeout", "after", *timeout)
 	default:
-		log.Fatal(err)
+		fatal(logger, "pipeline failed", "error", err)
 	}
 
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestWriteDefinitionRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	for _, name := range []string{in, out} {
+		if err := os.WriteFile(name, []byte("foo\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	def := `nodes:
+- name: src
+  type: read_file
+  args:
+    filename: ` + in + `
+- name: sink
+  type: write_file
+  args:
+    filename: ` + out + `
+    ifexists: overwrite
+  fetch:
+  - src
+`
+	defined, err := loadDefinition(strings.NewReader(def), facilities, nil)
+	if err != nil {
+		t.Fatalf("loadDefinition() err = %v", err)
+	}
+	p, err := createPipeline(defined)
+	if err != nil {
+		t.Fatalf("createPipeline() err = %v", err)
+	}
+	buff := &bytes.Buffer{}
+	if err := selina.WriteDefinition(buff, p); err != nil {
+		t.Fatalf("WriteDefinition() err = %v", err)
+	}
+	if !strings.Contains(buff.String(), "ifexists: overwrite") {
+		t.Fatalf("WriteDefinition() = %s, missing ifexists", buff.String())
+	}
+	// out.txt already exists, so it can be loaded again only if ifexists is kept
+	exported := buff.String()
+	if _, err := loadDefinition(buff, facilities, nil); err != nil {
+		t.Fatalf("loadDefinition() exported definition err = %v\n%s", err, exported)
+	}
+}
+
+func TestSchemaRetryNeedsRetries(t *testing.T) {
+	def := `nodes:
+- name: src
+  type: random
+  args:
+    len: 8
+  on_error:
+    mode: retry
+    retries: 0
+`
+	if _, err := loadDefinition(strings.NewReader(def), facilities, nil); !errors.Is(err, selina.ErrInvalidErrorPolicy) {
+		t.Fatalf("loadDefinition() err = %v", err)
+	}
+	// schema must reject it too
+	var sc struct {
+		Properties struct {
+			Nodes struct {
+				Items struct {
+					Properties struct {
+						OnError struct {
+							AllOf []struct {
+								If struct {
+									Properties struct {
+										Mode struct {
+											Const string `json:"const"`
+										} `json:"mode"`
+									} `json:"properties"`
+								} `json:"if"`
+								Then struct {
+									Required   []string                          `json:"required"`
+									Properties map[string]map[string]interface{} `json:"properties"`
+								} `json:"then"`
+							} `json:"allOf"`
+						} `json:"on_error"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"nodes"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(schema(facilities)), &sc); err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range sc.Properties.Nodes.Items.Properties.OnError.AllOf {
+		if rule.If.Properties.Mode.Const != string(selina.ErrorRetry) {
+			continue
+		}
+		if len(rule.Then.Required) != 1 || rule.Then.Required[0] != "retries" || rule.Then.Properties["retries"]["minimum"] != 1.0 {
+			t.Fatalf("retry rule = %+v", rule.Then)
+		}
+		return
+	}
+	t.Fatalf("schema has no rule for retry mode")
+}
@@ -0,0 +1,170 @@
+package selina
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v2"
+)
+
+// Codec is a named pair of Marshaler and Unmarshaler
+type Codec struct {
+	Marshal   Marshaler
+	Unmarshal Unmarshaler
+}
+
+// ErrUnknownCodec is returned when a codec name is not registered
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// ErrRawCodec is returned when raw codec receive a value that is not a byte sequence
+var ErrRawCodec = errors.New("raw codec only support []byte and string")
+
+var (
+	codecsMx sync.RWMutex
+	codecs   = map[string]Codec{
+		"json":    {Marshal: json.Marshal, Unmarshal: json.Unmarshal},
+		"msgpack": {Marshal: msgpack.Marshal, Unmarshal: msgpack.Unmarshal},
+		"yaml":    {Marshal: yaml.Marshal, Unmarshal: yamlUnmarshal},
+		"gob":     {Marshal: gobMarshal, Unmarshal: gobUnmarshal},
+		"raw":     {Marshal: rawMarshal, Unmarshal: rawUnmarshal},
+	}
+)
+
+func init() {
+	// generic values decoded by other codecs are sent as interfaces
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// RegisterCodec add or replace a codec in the registry
+func RegisterCodec(name string, c Codec) {
+	codecsMx.Lock()
+	defer codecsMx.Unlock()
+	codecs[name] = c
+}
+
+// LookupCodec return codec registered with name
+func LookupCodec(name string) (Codec, error) {
+	codecsMx.RLock()
+	defer codecsMx.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return Codec{}, fmt.Errorf("%w '%s'", ErrUnknownCodec, name)
+	}
+	return c, nil
+}
+
+// Codecs return all registered codec names sorted
+func Codecs() []string {
+	codecsMx.RLock()
+	defer codecsMx.RUnlock()
+	ret := make([]string, 0, len(codecs))
+	for k := range codecs {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// CodecName returns the name of registered codec which Marshal or Unmarshal
+// function is f, ok is false when f does not belong to any codec
+func CodecName(f interface{}) (name string, ok bool) {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "", false
+	}
+	for _, name := range Codecs() {
+		c, _ := LookupCodec(name)
+		if reflect.ValueOf(c.Marshal).Pointer() == v.Pointer() ||
+			reflect.ValueOf(c.Unmarshal).Pointer() == v.Pointer() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// yaml.v2 decode maps as map[interface{}]interface{} that cannot be encoded
+// by other codecs, so keys are converted to strings
+func yamlUnmarshal(data []byte, v interface{}) error {
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case *interface{}:
+		*t = stringKeys(*t)
+	case *map[string]interface{}:
+		for k, val := range *t {
+			(*t)[k] = stringKeys(val)
+		}
+	}
+	return nil
+}
+
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = stringKeys(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func gobMarshal(v interface{}) ([]byte, error) {
+	b := &bytes.Buffer{}
+	if err := gob.NewEncoder(b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func gobUnmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func rawMarshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	case *[]byte:
+		return *t, nil
+	case *string:
+		return []byte(*t), nil
+	case *interface{}:
+		return rawMarshal(*t)
+	default:
+		return nil, ErrRawCodec
+	}
+}
+
+func rawUnmarshal(data []byte, v interface{}) error {
+	cp := append([]byte(nil), data...)
+	switch t := v.(type) {
+	case *[]byte:
+		*t = cp
+	case *string:
+		*t = string(cp)
+	case *interface{}:
+		*t = cp
+	default:
+		return ErrRawCodec
+	}
+	return nil
+}
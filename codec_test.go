@@ -0,0 +1,69 @@
+package selina_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	value := map[string]interface{}{"name": "Selina", "tags": []interface{}{"a", "b"}}
+	for _, name := range []string{"json", "msgpack", "yaml", "gob"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := selina.LookupCodec(name)
+			if err != nil {
+				t.Fatalf("LookupCodec() err = %v", err)
+			}
+			data, err := c.Marshal(value)
+			if err != nil {
+				t.Fatalf("Marshal() err = %v", err)
+			}
+			got := make(map[string]interface{})
+			if err := c.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() err = %v", err)
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Fatalf("Unmarshal() got = %#v, want = %#v", got, value)
+			}
+		})
+	}
+}
+
+func TestCodecRaw(t *testing.T) {
+	c, err := selina.LookupCodec("raw")
+	if err != nil {
+		t.Fatalf("LookupCodec() err = %v", err)
+	}
+	var got interface{}
+	if err := c.Unmarshal([]byte("data"), &got); err != nil {
+		t.Fatalf("Unmarshal() err = %v", err)
+	}
+	b, err := c.Marshal(&got)
+	if err != nil || string(b) != "data" {
+		t.Fatalf("Marshal() = %s, %v", b, err)
+	}
+	if _, err := c.Marshal(1); !errors.Is(err, selina.ErrRawCodec) {
+		t.Fatalf("Marshal() err = %v", err)
+	}
+}
+
+func TestLookupCodecUnknown(t *testing.T) {
+	if _, err := selina.LookupCodec("xml"); !errors.Is(err, selina.ErrUnknownCodec) {
+		t.Fatalf("LookupCodec() err = %v", err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	selina.RegisterCodec("test", selina.Codec{Marshal: selina.DefaultMarshaler, Unmarshal: selina.DefaultUnmarshaler})
+	found := false
+	for _, n := range selina.Codecs() {
+		if n == "test" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Codecs() does not contain registered codec")
+	}
+}
@@ -0,0 +1,210 @@
+package selina
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrNotDescribable is returned when a worker can not be written as a definition
+var ErrNotDescribable = errors.New("worker is not describable")
+
+// Describer is implemented by workers that can be written in a definition file,
+// typ is the node type and args its arguments as used by selina command,
+// read_format and write_format args are written as node formats
+type Describer interface {
+	Describe() (typ string, args map[string]interface{}, err error)
+}
+
+// DescribeFormat set args[key] to the codec name of f, nothing is set if f is nil,
+// it is a helper for Describer implementations
+func DescribeFormat(args map[string]interface{}, key string, f interface{}) error {
+	if v := reflect.ValueOf(f); !v.IsValid() || (v.Kind() == reflect.Func && v.IsNil()) {
+		return nil
+	}
+	name, ok := CodecName(f)
+	if !ok {
+		return fmt.Errorf("%w: %s is not a registered codec", ErrNotDescribable, key)
+	}
+	args[key] = name
+	return nil
+}
+
+type definition struct {
+	Nodes []nodeDefinition `yaml:"nodes"`
+}
+
+type nodeDefinition struct {
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	Args        map[string]interface{} `yaml:"args,omitempty"`
+	ReadFormat  string                 `yaml:"read_format,omitempty"`
+	WriteFormat string                 `yaml:"write_format,omitempty"`
+	Fetch       []interface{}          `yaml:"fetch,omitempty"`
+	OnError     *onErrorDefinition     `yaml:"on_error,omitempty"`
+}
+
+type fetchDefinition struct {
+	Node     string             `yaml:"node"`
+	Priority int                `yaml:"priority,omitempty"`
+	Weight   int                `yaml:"weight,omitempty"`
+	Durable  *durableDefinition `yaml:"durable,omitempty"`
+}
+
+type durableDefinition struct {
+	Dir         string `yaml:"dir"`
+	SegmentSize int64  `yaml:"segment_size,omitempty"`
+	Sync        bool   `yaml:"sync,omitempty"`
+}
+
+type onErrorDefinition struct {
+	Mode    string `yaml:"mode"`
+	Retries int    `yaml:"retries,omitempty"`
+	Backoff string `yaml:"backoff,omitempty"`
+	Output  string `yaml:"output,omitempty"`
+}
+
+// WriteDefinition write p as a YAML definition file that can be loaded
+// with selina command, all workers must implement Describer
+// and node names must be unique
+func WriteDefinition(w io.Writer, p Pipeliner) error {
+	nodes := sortNodes(p.Nodes())
+	names := make(map[string]string, len(nodes))
+	seen := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		if seen[n.Name()] {
+			return fmt.Errorf("duplicated node name '%s'", n.Name())
+		}
+		seen[n.Name()] = true
+		names[n.ID()] = n.Name()
+	}
+	def := definition{Nodes: make([]nodeDefinition, 0, len(nodes))}
+	for _, n := range nodes {
+		nd, err := describeNode(n, names)
+		if err != nil {
+			return fmt.Errorf("node %s : %w", n.Name(), err)
+		}
+		def.Nodes = append(def.Nodes, nd)
+	}
+	for _, n := range nodes {
+		for id, opts := range n.chained {
+			for i := range def.Nodes {
+				if def.Nodes[i].Name != names[id] {
+					continue
+				}
+				if opts == (EdgeOptions{}) {
+					def.Nodes[i].Fetch = append(def.Nodes[i].Fetch, n.Name())
+					continue
+				}
+				fd := fetchDefinition{Node: n.Name(), Priority: opts.Priority, Weight: opts.Weight}
+				if d := opts.Durable; d != nil {
+					if _, ok := d.Fs.(*afero.OsFs); d.Fs != nil && !ok {
+						return fmt.Errorf("node %s : %w: durable edge is not in OS filesystem", names[id], ErrNotDescribable)
+					}
+					fd.Durable = &durableDefinition{Dir: d.Dir, SegmentSize: d.SegmentSize, Sync: d.Sync}
+				}
+				def.Nodes[i].Fetch = append(def.Nodes[i].Fetch, fd)
+			}
+		}
+	}
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func describeNode(n *Node, names map[string]string) (nodeDefinition, error) {
+	d, ok := n.w.(Describer)
+	if !ok {
+		return nodeDefinition{}, fmt.Errorf("%w: %T", ErrNotDescribable, n.w)
+	}
+	typ, args, err := d.Describe()
+	if err != nil {
+		return nodeDefinition{}, err
+	}
+	nd := nodeDefinition{Name: n.Name(), Type: typ, Args: make(map[string]interface{}, len(args))}
+	for k, v := range args {
+		switch k {
+		case "read_format":
+			nd.ReadFormat, _ = v.(string)
+		case "write_format":
+			nd.WriteFormat, _ = v.(string)
+		default:
+			nd.Args[k] = v
+		}
+	}
+	if len(n.errNext) > 1 {
+		return nodeDefinition{}, fmt.Errorf("%w: more than one error output", ErrNotDescribable)
+	}
+	pol := n.errs.policy
+	if pol.Mode == "" {
+		pol.Mode = ErrorFail
+	}
+	if pol.Mode == ErrorFail && len(n.errNext) == 0 {
+		return nd, nil
+	}
+	nd.OnError = &onErrorDefinition{Mode: string(pol.Mode), Retries: pol.Retries}
+	if pol.Backoff > 0 {
+		nd.OnError.Backoff = pol.Backoff.String()
+	}
+	for id := range n.errNext {
+		nd.OnError.Output = names[id]
+	}
+	return nd, nil
+}
+
+// sortNodes returns nodes in topological order, sources first, nodes
+// at the same level or in a cycle are sorted by name
+func sortNodes(nodes []*Node) []*Node {
+	byID := make(map[string]*Node, len(nodes))
+	inDegree := make(map[string]int, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID()] = n
+	}
+	for _, n := range nodes {
+		for _, next := range append(n.Next(), n.NextErrors()...) {
+			if _, ok := byID[next]; ok {
+				inDegree[next]++
+			}
+		}
+	}
+	byName := func(s []*Node) {
+		sort.SliceStable(s, func(i, j int) bool { return s[i].Name() < s[j].Name() })
+	}
+	ret := make([]*Node, 0, len(nodes))
+	done := make(map[string]bool, len(nodes))
+	for len(ret) < len(nodes) {
+		var ready []*Node
+		for _, n := range nodes {
+			if !done[n.ID()] && inDegree[n.ID()] == 0 {
+				ready = append(ready, n)
+			}
+		}
+		if len(ready) == 0 {
+			// a cycle, break it with first pending node
+			for _, n := range nodes {
+				if !done[n.ID()] {
+					ready = append(ready, n)
+				}
+			}
+			byName(ready)
+			ready = ready[:1]
+		}
+		byName(ready)
+		for _, n := range ready {
+			done[n.ID()] = true
+			ret = append(ret, n)
+			for _, next := range append(n.Next(), n.NextErrors()...) {
+				inDegree[next]--
+			}
+		}
+	}
+	return ret
+}
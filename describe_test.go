@@ -0,0 +1,93 @@
+package selina_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+type describedWorker struct {
+	dummyWorker
+	typ  string
+	args map[string]interface{}
+}
+
+func (d *describedWorker) Describe() (string, map[string]interface{}, error) {
+	return d.typ, d.args, nil
+}
+
+func TestWriteDefinition(t *testing.T) {
+	src := selina.NewNode("src", &describedWorker{typ: "cron", args: map[string]interface{}{"spec": "@every 1s"}})
+	enc := selina.NewNode("enc", &describedWorker{typ: "csv", args: map[string]interface{}{"mode": "encode", "read_format": "json"}})
+	dead := selina.NewNode("dead", &describedWorker{typ: "write_file", args: map[string]interface{}{"filename": "dead.txt"}})
+	sink := selina.NewNode("sink", &describedWorker{typ: "write_file", args: map[string]interface{}{"filename": "out.txt"}})
+	if err := enc.SetErrorPolicy(selina.ErrorPolicy{Mode: selina.ErrorRoute}); err != nil {
+		t.Fatal(err)
+	}
+	src.ChainWith(enc, selina.EdgeOptions{Priority: 1})
+	enc.Chain(sink)
+	enc.ChainError(dead)
+	p := selina.FreePipeline(sink, dead, enc, src)
+	buff := &bytes.Buffer{}
+	if err := selina.WriteDefinition(buff, p); err != nil {
+		t.Fatalf("WriteDefinition() err = %v", err)
+	}
+	want := `nodes:
+- name: src
+  type: cron
+  args:
+    spec: '@every 1s'
+- name: enc
+  type: csv
+  args:
+    mode: encode
+  read_format: json
+  fetch:
+  - node: src
+    priority: 1
+  on_error:
+    mode: route
+    output: dead
+- name: dead
+  type: write_file
+  args:
+    filename: dead.txt
+- name: sink
+  type: write_file
+  args:
+    filename: out.txt
+  fetch:
+  - enc
+`
+	if buff.String() != want {
+		t.Fatalf("WriteDefinition() got = %s, want %s", buff.String(), want)
+	}
+}
+
+func TestWriteDefinitionNotDescribable(t *testing.T) {
+	p := selina.LinealPipeline(selina.NewNode("a", &dummyWorker{}), selina.NewNode("b", &dummyWorker{}))
+	if err := selina.WriteDefinition(&bytes.Buffer{}, p); !errors.Is(err, selina.ErrNotDescribable) {
+		t.Fatalf("WriteDefinition() err = %v", err)
+	}
+}
+
+func TestDescribeFormat(t *testing.T) {
+	args := make(map[string]interface{})
+	if err := selina.DescribeFormat(args, "write_format", selina.Marshaler(json.Marshal)); err != nil {
+		t.Fatalf("DescribeFormat() err = %v", err)
+	}
+	var nilFormat selina.Unmarshaler
+	if err := selina.DescribeFormat(args, "read_format", nilFormat); err != nil {
+		t.Fatalf("DescribeFormat() err = %v", err)
+	}
+	if len(args) != 1 || args["write_format"] != "json" {
+		t.Fatalf("DescribeFormat() args = %v", args)
+	}
+	custom := func(interface{}) ([]byte, error) { return nil, nil }
+	if err := selina.DescribeFormat(args, "write_format", custom); !errors.Is(err, selina.ErrNotDescribable) {
+		t.Fatalf("DescribeFormat() err = %v", err)
+	}
+}
@@ -0,0 +1,671 @@
+package selina
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultSegmentSize is the size of a durable edge segment when DurableOptions.SegmentSize is zero
+const DefaultSegmentSize = 64 << 20
+
+const (
+	segmentExt  = ".log"
+	commitFile  = "commit"
+	retryFile   = "retry"
+	headerSize  = 8
+	positionLen = 16
+	// markFlag is set in length of records that store a watermark
+	markFlag = 1 << 31
+	// removedFlag is set in segment of retries entries that remove a position
+	removedFlag = 1 << 63
+)
+
+// ErrInvalidDurable is returned when DurableOptions has invalid values
+var ErrInvalidDurable = errors.New("invalid durable options")
+
+// DurableOptions make an edge durable, messages are written to an append-only
+// log of segments in Dir and read by downstream node at its own pace, on restart
+// downstream node resume from the last acknowledged message (see OnAck),
+// nacked messages are delivered again after restart before the rest of the log,
+// every durable edge must use its own Dir
+type DurableOptions struct {
+	// Fs default afero.NewOsFs()
+	Fs afero.Fs
+	// Dir where segments and commit position are stored
+	Dir string
+	// SegmentSize a new segment is started when current one is bigger than this, default DefaultSegmentSize
+	SegmentSize int64
+	// Sync flush every record, commit and retry position to disk before it is
+	// acknowledged, messages survive a power loss but writes are much slower
+	Sync bool
+}
+
+// Check if a combination of options is valid
+func (o DurableOptions) Check() error {
+	if o.Dir == "" {
+		return fmt.Errorf("%w: empty dir", ErrInvalidDurable)
+	}
+	if o.SegmentSize < 0 {
+		return fmt.Errorf("%w: negative segment size", ErrInvalidDurable)
+	}
+	return nil
+}
+
+type position struct {
+	seg int64
+	off int64
+}
+
+// segmentLog is the storage of a durable edge, it is written by produce and read by consume
+type segmentLog struct {
+	fs      afero.Fs
+	dir     string
+	segSize int64
+	sync    bool
+
+	mtx    sync.Mutex
+	cond   *sync.Cond
+	wfile  afero.File
+	write  position
+	closed bool
+	// err is the first failure of log, it stops consume
+	err error
+	// quit cancels consume, stopped is set when it is closed
+	quit    <-chan struct{}
+	stopped bool
+
+	// commit tracking, pending are delivered messages not yet acknowledged
+	// and retries are start positions of nacked messages, retries file is
+	// append-only and roff is its size
+	cmtx  sync.Mutex
+	cfile afero.File
+	rfile afero.File
+	roff  int64
+	// first segment that is not removed yet
+	first     int64
+	pending   []*delivery
+	retries   []position
+	consumed  bool
+	committed position
+}
+
+type delivery struct {
+	start position
+	end   position
+	// retry is true for messages nacked in a previous run
+	retry bool
+	done  bool
+	err   error
+}
+
+func segmentName(seg int64) string {
+	return fmt.Sprintf("%020d%s", seg, segmentExt)
+}
+
+// openLog open or create log in opts.Dir and returns the position of first not committed message
+func openLog(opts DurableOptions) (*segmentLog, position, error) {
+	if err := opts.Check(); err != nil {
+		return nil, position{}, err
+	}
+	l := &segmentLog{fs: opts.Fs, dir: opts.Dir, segSize: opts.SegmentSize, sync: opts.Sync}
+	if l.fs == nil {
+		l.fs = afero.NewOsFs()
+	}
+	if l.segSize == 0 {
+		l.segSize = DefaultSegmentSize
+	}
+	l.cond = sync.NewCond(&l.mtx)
+	if err := l.fs.MkdirAll(l.dir, 0755); err != nil {
+		return nil, position{}, err
+	}
+	segs, err := l.segments()
+	if err != nil {
+		return nil, position{}, err
+	}
+	if len(segs) == 0 {
+		segs = []int64{0}
+	}
+	l.first = segs[0]
+	l.write.seg = segs[len(segs)-1]
+	if l.wfile, err = l.fs.OpenFile(path.Join(l.dir, segmentName(l.write.seg)), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return nil, position{}, err
+	}
+	// a crash can leave an incomplete record at the end of last segment
+	if l.write.off, err = validSize(l.wfile); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	if err := l.wfile.Truncate(l.write.off); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	if _, err := l.wfile.Seek(l.write.off, io.SeekStart); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	if l.cfile, err = l.fs.OpenFile(path.Join(l.dir, commitFile), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	start := position{seg: l.first}
+	buf := make([]byte, positionLen)
+	if _, err := l.cfile.ReadAt(buf, 0); err == nil {
+		c := position{seg: int64(binary.BigEndian.Uint64(buf)), off: int64(binary.BigEndian.Uint64(buf[8:]))}
+		if c.seg >= l.first {
+			start = c
+		}
+	}
+	l.committed = start
+	if l.rfile, err = l.fs.OpenFile(path.Join(l.dir, retryFile), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	data, err := afero.ReadAll(l.rfile)
+	if err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	for ; len(data) >= positionLen; data = data[positionLen:] {
+		seg := binary.BigEndian.Uint64(data)
+		r := position{seg: int64(seg &^ removedFlag), off: int64(binary.BigEndian.Uint64(data[8:]))}
+		switch {
+		case seg&removedFlag != 0:
+			l.removeRetry(r)
+		case r.seg >= l.first:
+			// segments of retries are never removed, older ones were lost
+			l.retries = append(l.retries, r)
+		}
+	}
+	// retries file is compacted once, then entries are only appended
+	if err := l.writeRetries(); err != nil {
+		return nil, position{}, l.closeAll(err)
+	}
+	return l, start, nil
+}
+
+func (l *segmentLog) closeAll(err error) error {
+	for _, f := range []afero.File{l.wfile, l.cfile, l.rfile} {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+	return err
+}
+
+// fail stop log with err, only first error is kept
+func (l *segmentLog) fail(err error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.cond.Broadcast()
+}
+
+// failure returns the error that stopped log
+func (l *segmentLog) failure() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.err
+}
+
+// segments returns all segment numbers sorted
+func (l *segmentLog) segments() ([]int64, error) {
+	infos, err := afero.ReadDir(l.fs, l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []int64
+	for _, fi := range infos {
+		name := fi.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seg, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, seg)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+// validSize returns the size of complete and valid records in f
+func validSize(f afero.File) (int64, error) {
+	var off int64
+	for {
+		data, _, err := readRecord(f, off)
+		if err == io.EOF || errors.Is(err, errCorrupted) {
+			return off, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		off += headerSize + int64(len(data))
+	}
+}
+
+var errCorrupted = errors.New("corrupted record")
+
+// readRecord read a record at off, a record is a length, a crc32 and data,
+// mark is true when data is a watermark
+func readRecord(f afero.File, off int64) (data []byte, mark bool, err error) {
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, off); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, false, errCorrupted
+		}
+		return nil, false, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	data = make([]byte, size&^markFlag)
+	if _, err := f.ReadAt(data, off+headerSize); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, false, errCorrupted
+		}
+		return nil, false, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, false, errCorrupted
+	}
+	return data, size&markFlag != 0, nil
+}
+
+// append write data as a new record, a new segment is created when current one is full
+func (l *segmentLog) append(data []byte, mark bool) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	size := int64(headerSize + len(data))
+	if l.write.off > 0 && l.write.off+size > l.segSize {
+		f, err := l.fs.OpenFile(path.Join(l.dir, segmentName(l.write.seg+1)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_ = l.wfile.Close()
+		l.wfile = f
+		l.write = position{seg: l.write.seg + 1}
+	}
+	rec := make([]byte, size)
+	length := uint32(len(data))
+	if mark {
+		length |= markFlag
+	}
+	binary.BigEndian.PutUint32(rec, length)
+	binary.BigEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(data))
+	copy(rec[headerSize:], data)
+	if _, err := l.wfile.Write(rec); err != nil {
+		return err
+	}
+	if l.sync {
+		if err := l.wfile.Sync(); err != nil {
+			return err
+		}
+	}
+	l.write.off += size
+	l.cond.Broadcast()
+	return nil
+}
+
+// produce write all messages from in to the log, messages are acknowledged
+// when written, after a write error all messages are nacked
+func (l *segmentLog) produce(in <-chan *bytes.Buffer) {
+	var failed error
+	for msg := range in {
+		if failed == nil {
+			if t, ok := markerTime(msg); ok {
+				failed = l.append(binary.BigEndian.AppendUint64(nil, uint64(t)), true)
+			} else {
+				failed = l.append(msg.Bytes(), false)
+			}
+			if failed != nil {
+				l.fail(failed)
+			}
+		}
+		Ack(msg, failed)
+		FreeBuffer(msg)
+	}
+	l.mtx.Lock()
+	l.closed = true
+	_ = l.wfile.Close()
+	l.cond.Broadcast()
+	l.mtx.Unlock()
+}
+
+// wait until there is a record at pos, returns false when log is
+// closed and everything was readed, log failed or consume was cancelled
+func (l *segmentLog) wait(pos position) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for l.err == nil && !l.stopped && pos.seg == l.write.seg && pos.off >= l.write.off {
+		if l.closed {
+			return false
+		}
+		l.cond.Wait()
+	}
+	return l.err == nil && !l.stopped
+}
+
+// stop wake up consume when quit is closed, until done is closed
+func (l *segmentLog) stop(done <-chan struct{}) {
+	select {
+	case <-l.quit:
+	case <-done:
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.stopped = true
+	l.cond.Broadcast()
+}
+
+// cancelled returns true when quit is closed
+func (l *segmentLog) cancelled() bool {
+	select {
+	case <-l.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// send msg to out, returns false when consume is cancelled before msg is
+// sent, then msg is freed and its record is read again after restart
+func (l *segmentLog) send(out chan<- *bytes.Buffer, msg *bytes.Buffer) bool {
+	select {
+	case out <- msg:
+		return true
+	case <-l.quit:
+		FreeBuffer(msg)
+		return false
+	}
+}
+
+// position returns current write position
+func (l *segmentLog) position() position {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.write
+}
+
+// grow wait until write position is not w, returns false when
+// log is closed or failed without new records
+func (l *segmentLog) grow(w position) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for l.err == nil && !l.closed && !l.stopped && l.write == w {
+		l.cond.Wait()
+	}
+	return l.err == nil && !l.stopped && l.write != w
+}
+
+// consume send every record from start to out, records nacked in a previous
+// run are sent first, every message is tracked to commit its position when acknowledged.
+// It stops reading when quit is closed
+func (l *segmentLog) consume(start position, out chan<- *bytes.Buffer) {
+	defer close(out)
+	defer l.finish()
+	done := make(chan struct{})
+	defer close(done)
+	go l.stop(done)
+	if !l.redeliver(out) {
+		return
+	}
+	pos := start
+	var f afero.File
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	for l.wait(pos) {
+		if f == nil {
+			var err error
+			if f, err = l.fs.Open(path.Join(l.dir, segmentName(pos.seg))); err != nil {
+				l.fail(err)
+				return
+			}
+		}
+		w := l.position()
+		data, mark, err := readRecord(f, pos.off)
+		switch {
+		case err == nil:
+		case err != io.EOF && !errors.Is(err, errCorrupted):
+			l.fail(err)
+			return
+		case w.seg > pos.seg:
+			// sealed segments are readed until its end or first corrupted record
+			_ = f.Close()
+			f = nil
+			pos = position{seg: pos.seg + 1}
+			continue
+		default:
+			// last record of write segment can be incomplete, it is readed again when log grows
+			if !l.grow(w) {
+				return
+			}
+			continue
+		}
+		end := position{seg: pos.seg, off: pos.off + headerSize + int64(len(data))}
+		if mark {
+			// watermarks do not need an ack, its position is committed as soon as possible
+			l.deliver(pos, end, false)(nil)
+			pos = end
+			if !l.send(out, newMarker(int64(binary.BigEndian.Uint64(data)))) {
+				return
+			}
+			continue
+		}
+		msg := GetBuffer()
+		msg.Write(data)
+		OnAck(msg, l.deliver(pos, end, false))
+		pos = end
+		if !l.send(out, msg) {
+			return
+		}
+	}
+}
+
+// redeliver send messages nacked in a previous run, returns false if log failed or was cancelled
+func (l *segmentLog) redeliver(out chan<- *bytes.Buffer) bool {
+	l.cmtx.Lock()
+	retries := append([]position(nil), l.retries...)
+	l.cmtx.Unlock()
+	for _, r := range retries {
+		data, err := l.readAt(r)
+		ack := l.deliver(r, r, true)
+		if err != nil {
+			// a lost record can not be delivered, it is removed from retries
+			ack(nil)
+			if err != io.EOF && !errors.Is(err, errCorrupted) && !errors.Is(err, os.ErrNotExist) {
+				l.fail(err)
+				return false
+			}
+			continue
+		}
+		msg := GetBuffer()
+		msg.Write(data)
+		OnAck(msg, ack)
+		if !l.send(out, msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// readAt returns data of record at pos
+func (l *segmentLog) readAt(pos position) ([]byte, error) {
+	f, err := l.fs.Open(path.Join(l.dir, segmentName(pos.seg)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, _, err := readRecord(f, pos.off)
+	return data, err
+}
+
+// deliver register a message from start to end, returned func commit it
+func (l *segmentLog) deliver(start, end position, retry bool) func(error) {
+	d := &delivery{start: start, end: end, retry: retry}
+	l.cmtx.Lock()
+	l.pending = append(l.pending, d)
+	l.cmtx.Unlock()
+	return func(err error) {
+		l.cmtx.Lock()
+		defer l.cmtx.Unlock()
+		d.done, d.err = true, err
+		l.commit()
+	}
+}
+
+// commit advance committed position over acknowledged and nacked messages,
+// start of a nacked message is stored in retries so it is delivered again after restart.
+// Once consume is cancelled a nack means message was not delivered, so commit stops
+// there and restart resumes from committed position
+func (l *segmentLog) commit() {
+	defer l.closeCommit()
+	advanced := false
+	var entries []byte
+	for len(l.pending) > 0 && l.pending[0].done {
+		d := l.pending[0]
+		if !d.retry && d.err != nil && l.cancelled() {
+			break
+		}
+		l.pending = l.pending[1:]
+		switch {
+		case d.retry && d.err == nil:
+			l.removeRetry(d.start)
+			entries = appendPosition(entries, d.start, true)
+		case d.retry:
+			// it is still in retries
+		default:
+			if d.err != nil {
+				l.retries = append(l.retries, d.start)
+				entries = appendPosition(entries, d.start, false)
+			}
+			l.committed = d.end
+			advanced = true
+		}
+	}
+	retried := len(entries) > 0
+	if l.cfile == nil || l.failure() != nil {
+		return
+	}
+	// retries are written first, a crash between both writes delivers a message twice instead of losing it
+	if retried {
+		if err := l.appendRetries(entries); err != nil {
+			l.fail(fmt.Errorf("writing retries %w", err))
+			return
+		}
+	}
+	if advanced {
+		if err := l.writeCommit(); err != nil {
+			l.fail(fmt.Errorf("writing commit %w", err))
+			return
+		}
+	}
+	if l.first < l.committed.seg {
+		// segments before committed position are not needed anymore unless they have retries
+		keep := make(map[int64]bool, len(l.retries))
+		for _, r := range l.retries {
+			keep[r.seg] = true
+		}
+		for ; l.first < l.committed.seg; l.first++ {
+			if !keep[l.first] {
+				_ = l.fs.Remove(path.Join(l.dir, segmentName(l.first)))
+			}
+		}
+	}
+}
+
+func (l *segmentLog) removeRetry(r position) {
+	for i, p := range l.retries {
+		if p == r {
+			l.retries = append(l.retries[:i], l.retries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *segmentLog) writeCommit() error {
+	buf := make([]byte, positionLen)
+	binary.BigEndian.PutUint64(buf, uint64(l.committed.seg))
+	binary.BigEndian.PutUint64(buf[8:], uint64(l.committed.off))
+	if _, err := l.cfile.WriteAt(buf, 0); err != nil {
+		return err
+	}
+	if l.sync {
+		return l.cfile.Sync()
+	}
+	return nil
+}
+
+// appendPosition append a retries entry for p, removed entries delete p
+func appendPosition(buf []byte, p position, removed bool) []byte {
+	seg := uint64(p.seg)
+	if removed {
+		seg |= removedFlag
+	}
+	buf = binary.BigEndian.AppendUint64(buf, seg)
+	return binary.BigEndian.AppendUint64(buf, uint64(p.off))
+}
+
+// writeRetries replace retries file with current retries
+func (l *segmentLog) writeRetries() error {
+	buf := make([]byte, 0, len(l.retries)*positionLen)
+	for _, r := range l.retries {
+		buf = appendPosition(buf, r, false)
+	}
+	if _, err := l.rfile.WriteAt(buf, 0); err != nil {
+		return err
+	}
+	if err := l.rfile.Truncate(int64(len(buf))); err != nil {
+		return err
+	}
+	l.roff = int64(len(buf))
+	if l.sync {
+		return l.rfile.Sync()
+	}
+	return nil
+}
+
+// appendRetries write entries at the end of retries file
+func (l *segmentLog) appendRetries(entries []byte) error {
+	if _, err := l.rfile.WriteAt(entries, l.roff); err != nil {
+		return err
+	}
+	l.roff += int64(len(entries))
+	if l.sync {
+		return l.rfile.Sync()
+	}
+	return nil
+}
+
+// finish is called when everything was readed
+func (l *segmentLog) finish() {
+	l.cmtx.Lock()
+	defer l.cmtx.Unlock()
+	l.consumed = true
+	l.closeCommit()
+}
+
+// closeCommit close commit file when all messages were acknowledged or nacked
+func (l *segmentLog) closeCommit() {
+	if !l.consumed || l.cfile == nil {
+		return
+	}
+	for _, d := range l.pending {
+		if !d.done {
+			return
+		}
+	}
+	_ = l.cfile.Close()
+	_ = l.rfile.Close()
+	l.cfile, l.rfile = nil, nil
+}
@@ -0,0 +1,193 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+	"github.com/spf13/afero"
+)
+
+// recordWriter keep every message and ack all of them except nack
+type recordWriter struct {
+	nack   string
+	values []string
+}
+
+func (w *recordWriter) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			w.values = append(w.values, msg.String())
+			if msg.String() != w.nack {
+				selina.Ack(msg, nil)
+			}
+			selina.FreeBuffer(msg)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func runDurable(t *testing.T, opts *selina.DurableOptions, values []string, nack string) []string {
+	t.Helper()
+	src := selina.NewNode("src", &sliceReader{values: values})
+	w := &recordWriter{nack: nack}
+	sink := selina.NewNode("sink", w)
+	src.ChainWith(sink, selina.EdgeOptions{Durable: opts})
+	if err := selina.FreePipeline(src, sink).Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	return w.values
+}
+
+func TestDurableEdge(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	opts := &selina.DurableOptions{Fs: fs, Dir: "/edge", SegmentSize: 20}
+	got := runDurable(t, opts, []string{"a", "b", "c", "d"}, "c")
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first run got = %v, want %v", got, want)
+	}
+	// c was nacked so it is delivered again before new messages
+	got = runDurable(t, opts, []string{"e"}, "")
+	if want := []string{"c", "e"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second run got = %v, want %v", got, want)
+	}
+	got = runDurable(t, opts, nil, "")
+	if len(got) != 0 {
+		t.Fatalf("third run got = %v, want nothing", got)
+	}
+	files, err := afero.ReadDir(fs, "/edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// committed segments are removed, only last segment, commit and retries remain
+	if len(files) != 3 {
+		t.Fatalf("files = %d, want 3", len(files))
+	}
+}
+
+func TestDurableEdgeNack(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	opts := &selina.DurableOptions{Fs: fs, Dir: "/edge", SegmentSize: 20, Sync: true}
+	runDurable(t, opts, []string{"a", "b", "c", "d", "e", "f"}, "a")
+	// commit advances after a nack, only segment of a and last one are kept
+	segments, err := afero.Glob(fs, "/edge/*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/edge/00000000000000000000.log", "/edge/00000000000000000002.log"}; !reflect.DeepEqual(segments, want) {
+		t.Fatalf("segments = %v, want %v", segments, want)
+	}
+	got := runDurable(t, opts, []string{"g"}, "")
+	if want := []string{"a", "g"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second run got = %v, want %v", got, want)
+	}
+	segments, err = afero.Glob(fs, "/edge/*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/edge/00000000000000000003.log"}; !reflect.DeepEqual(segments, want) {
+		t.Fatalf("segments = %v, want %v", segments, want)
+	}
+}
+
+// cancelWriter ack first n messages, then cancel pipeline once src wrote last value
+type cancelWriter struct {
+	n      int
+	src    *ackSource
+	last   string
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for i := 0; ; i++ {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			if i < w.n {
+				selina.Ack(msg, nil)
+				selina.FreeBuffer(msg)
+				continue
+			}
+			for {
+				if _, ok := w.src.result(w.last); ok {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			w.cancel()
+			<-ctx.Done()
+			selina.FreeBuffer(msg)
+			return ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestDurableEdgeCancel(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	opts := &selina.DurableOptions{Fs: fs, Dir: "/edge", SegmentSize: 1024}
+	values := make([]string, 5000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &ackSource{values: values}
+	srcNode := selina.NewNode("src", src)
+	sink := selina.NewNode("sink", &cancelWriter{n: 10, src: src, last: values[len(values)-1], cancel: cancel})
+	srcNode.ChainWith(sink, selina.EdgeOptions{Durable: opts})
+	if err := selina.FreePipeline(srcNode, sink).Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() err = %v", err)
+	}
+	// backlog is not delivered, so it is not stored as retries
+	info, err := fs.Stat("/edge/retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("retry size = %d, want 0", info.Size())
+	}
+	// restart resumes after last acknowledged message
+	got := runDurable(t, opts, nil, "")
+	if !reflect.DeepEqual(got, values[10:]) {
+		t.Fatalf("second run got %d values starting with %v, want %d", len(got), got[:min(len(got), 3)], len(values)-10)
+	}
+}
+
+func TestDurableEdgeAck(t *testing.T) {
+	src := &ackSource{values: []string{"a"}}
+	srcNode := selina.NewNode("src", src)
+	sink := selina.NewNode("sink", &sliceWriter{})
+	srcNode.ChainWith(sink, selina.EdgeOptions{Durable: &selina.DurableOptions{Fs: afero.NewMemMapFs(), Dir: "/edge"}})
+	if err := selina.FreePipeline(srcNode, sink).Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	// upstream messages are acknowledged when written to the log
+	if err, ok := src.result("a"); !ok || err != nil {
+		t.Fatalf("ack = %v (%v)", err, ok)
+	}
+}
+
+func TestDurableEdgeInvalid(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{})
+	sink := selina.NewNode("sink", &sliceWriter{})
+	src.ChainWith(sink, selina.EdgeOptions{Durable: &selina.DurableOptions{}})
+	err := selina.FreePipeline(src, sink).Run(context.Background())
+	if !errors.Is(err, selina.ErrInvalidDurable) {
+		t.Fatalf("Run() err = %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package selina
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a pipeline or node lifecycle event, use a type switch to get its values
+type Event interface {
+	event()
+}
+
+// NodeEvent fields common to all node events
+type NodeEvent struct {
+	Node string
+	ID   string
+	Time time.Time
+}
+
+func (NodeEvent) event() {}
+
+// NodeStarted is emitted when Node.Start calls Worker.Process for first time
+type NodeStarted struct {
+	NodeEvent
+}
+
+// NodeFinished is emitted when Node.Start returns, Err is its return value
+type NodeFinished struct {
+	NodeEvent
+	Err error
+}
+
+// FirstMessage is emitted when a node receives its first message,
+// nodes without upstream emit it when they produce their first message
+type FirstMessage struct {
+	NodeEvent
+}
+
+// InputClosed is emitted when all upstream nodes are finished
+type InputClosed struct {
+	NodeEvent
+}
+
+// NodeRestarted is emitted before a worker is restarted by RestartPolicy
+type NodeRestarted struct {
+	NodeEvent
+	Attempt int
+	Err     error
+}
+
+// PipelineCompleted is emitted when Pipeliner.Run returns
+type PipelineCompleted struct {
+	Time time.Time
+	Err  error
+}
+
+func (PipelineCompleted) event() {}
+
+// Observer receive events, it is called synchronously so it must not block
+type Observer func(Event)
+
+// ChannelObserver returns an Observer that sends events to c without blocking
+// nodes, events are dropped while c is full so it must be buffered and drained
+func ChannelObserver(c chan<- Event) Observer {
+	return func(e Event) {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+type observerKey struct{}
+
+// WithObserver returns a context that carries o, pass it to Pipeliner.Run
+// to observe all nodes and the pipeline itself, it can be called many times
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	obs := append(observersFromContext(ctx), o)
+	return context.WithValue(ctx, observerKey{}, obs)
+}
+
+func observersFromContext(ctx context.Context) []Observer {
+	obs, _ := ctx.Value(observerKey{}).([]Observer)
+	// copy so WithObserver never share backing array
+	return append([]Observer(nil), obs...)
+}
+
+func notify(obs []Observer, e Event) {
+	for _, o := range obs {
+		o(e)
+	}
+}
@@ -0,0 +1,144 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+type eventLog struct {
+	mtx    sync.Mutex
+	events []selina.Event
+}
+
+func (l *eventLog) observe(e selina.Event) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.events = append(l.events, e)
+}
+
+// kinds returns event type names received by node
+func (l *eventLog) kinds(node string) map[string]int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	ret := make(map[string]int)
+	for _, e := range l.events {
+		switch v := e.(type) {
+		case selina.NodeStarted:
+			if v.Node == node {
+				ret["started"]++
+			}
+		case selina.NodeFinished:
+			if v.Node == node {
+				ret["finished"]++
+			}
+		case selina.FirstMessage:
+			if v.Node == node {
+				ret["first"]++
+			}
+		case selina.InputClosed:
+			if v.Node == node {
+				ret["closed"]++
+			}
+		case selina.NodeRestarted:
+			if v.Node == node {
+				ret["restarted"]++
+			}
+		}
+	}
+	return ret
+}
+
+func TestPipelineEvents(t *testing.T) {
+	log := &eventLog{}
+	src := selina.NewNode("src", &sliceReader{values: []string{"1", "2"}})
+	mid := selina.NewNode("mid", &dummyWorker{})
+	w := &sliceWriter{}
+	out := selina.NewNode("out", w)
+	src.Chain(mid).Chain(out)
+	p := selina.FreePipeline(src, mid, out)
+	if err := p.Run(selina.WithObserver(context.Background(), log.observe)); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if len(w.values) != 2 {
+		t.Fatalf("Run() got = %v", w.values)
+	}
+	want := map[string]map[string]int{
+		"src": {"started": 1, "first": 1, "finished": 1},
+		"mid": {"started": 1, "first": 1, "closed": 1, "finished": 1},
+		"out": {"started": 1, "first": 1, "closed": 1, "finished": 1},
+	}
+	for node, kinds := range want {
+		got := log.kinds(node)
+		for k, v := range kinds {
+			if got[k] != v {
+				t.Fatalf("node %s events = %v, want %v", node, got, kinds)
+			}
+		}
+	}
+	last := log.events[len(log.events)-1]
+	if c, ok := last.(selina.PipelineCompleted); !ok || c.Err != nil {
+		t.Fatalf("last event = %#v", last)
+	}
+}
+
+func TestNodeObserve(t *testing.T) {
+	events := make(chan selina.Event, 16)
+	n := selina.NewNode("bad", panicOn("boom", 2))
+	n.SetRestartPolicy(selina.RestartPolicy{MaxRestarts: 1})
+	n.Observe(selina.ChannelObserver(events))
+	src := selina.NewNode("src", &sliceReader{values: []string{"boom", "boom"}})
+	src.Chain(n)
+	p := selina.FreePipeline(src, n)
+	err := p.Run(context.Background())
+	var perr *selina.PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Run() err = %v", err)
+	}
+	close(events)
+	var restarted, finished bool
+	for e := range events {
+		switch v := e.(type) {
+		case selina.NodeRestarted:
+			restarted = v.Attempt == 1 && v.Err != nil
+		case selina.NodeFinished:
+			finished = errors.As(v.Err, &perr)
+		case selina.PipelineCompleted:
+			t.Fatal("node observer received PipelineCompleted")
+		}
+	}
+	if !restarted || !finished {
+		t.Fatalf("restarted = %v, finished = %v", restarted, finished)
+	}
+}
+
+func TestPipelineObserve(t *testing.T) {
+	log := &eventLog{}
+	src := selina.NewNode("src", &sliceReader{values: []string{"1"}})
+	out := selina.NewNode("out", &sliceWriter{})
+	p := selina.Observe(selina.LinealPipeline(src, out), log.observe)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if got := log.kinds("out"); got["started"] != 1 || got["finished"] != 1 {
+		t.Fatalf("node out events = %v", got)
+	}
+	last := log.events[len(log.events)-1]
+	if _, ok := last.(selina.PipelineCompleted); !ok {
+		t.Fatalf("last event = %#v", last)
+	}
+}
+
+func TestChannelObserverDrop(t *testing.T) {
+	events := make(chan selina.Event, 1)
+	o := selina.ChannelObserver(events)
+	// a full channel must not block the caller
+	o(selina.NodeStarted{})
+	o(selina.NodeStarted{})
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+}
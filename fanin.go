@@ -0,0 +1,203 @@
+package selina
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+)
+
+// EdgeOptions configure how a node receives messages from an upstream node
+type EdgeOptions struct {
+	// Priority messages from upstreams with a higher value are always delivered first
+	Priority int
+	// Weight share of messages among ready upstreams with same Priority,
+	// with weights 3 and 1 first upstream receive three messages for each one
+	// of second, default 1
+	Weight int
+	// Durable when not nil messages are stored in a log before
+	// they are delivered (see DurableOptions)
+	Durable *DurableOptions
+}
+
+func (o EdgeOptions) weight() int {
+	if o.Weight <= 0 {
+		return 1
+	}
+	return o.Weight
+}
+
+// prioritized is true when any edge needs the scheduler
+func (r *Receiver) prioritized() bool {
+	for _, e := range r.edges {
+		if e.opts.Priority != 0 || e.opts.weight() != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// member is an upstream of scheduler, index is its edge in Receiver
+type member struct {
+	in    <-chan *bytes.Buffer
+	index int
+	// level is the position of member group, lower levels have higher priority
+	level   int
+	marks   *inputMarks
+	weight  int
+	credits int
+	closed  bool
+}
+
+// close exclude m from input watermark
+func (m *member) close() {
+	m.closed = true
+	if m.marks != nil {
+		m.marks.close(m.index)
+	}
+}
+
+// group are upstreams with same priority served by weighted round robin
+type group struct {
+	members []*member
+	cursor  int
+}
+
+// pick returns a message from a ready member without blocking
+func (g *group) pick() (*member, *bytes.Buffer, bool) {
+	for round := 0; round < 2; round++ {
+		for k := 0; k < len(g.members); k++ {
+			m := g.members[g.cursor]
+			if !m.closed && m.credits > 0 {
+				select {
+				case msg, ok := <-m.in:
+					if ok {
+						m.credits--
+						if m.credits == 0 {
+							g.cursor = (g.cursor + 1) % len(g.members)
+						}
+						return m, msg, true
+					}
+					m.close()
+				default:
+				}
+			}
+			g.cursor = (g.cursor + 1) % len(g.members)
+		}
+		// nobody with credits is ready, start a new round
+		for _, m := range g.members {
+			m.credits = m.weight
+		}
+	}
+	return nil, nil, false
+}
+
+// schedule deliver messages by strict priority and weighted round robin
+// among upstreams with same priority, output is closed when all inputs are closed
+func (r *Receiver) schedule() {
+	defer close(r.out)
+	byPriority := make(map[int]*group)
+	all := make([]*member, 0, len(r.edges))
+	for i, e := range r.edges {
+		g, ok := byPriority[e.opts.Priority]
+		if !ok {
+			g = &group{}
+			byPriority[e.opts.Priority] = g
+		}
+		m := &member{in: e.in, index: i, marks: r.marks, weight: e.opts.weight(), credits: e.opts.weight()}
+		g.members = append(g.members, m)
+		all = append(all, m)
+	}
+	priorities := make([]int, 0, len(byPriority))
+	for p := range byPriority {
+		priorities = append(priorities, p)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+	groups := make([]*group, len(priorities))
+	for i, p := range priorities {
+		groups[i] = byPriority[p]
+		for _, m := range groups[i].members {
+			m.level = i
+		}
+	}
+	sel := newSelector(all)
+	for {
+		m, msg, ok := pickFirst(groups)
+		if !ok {
+			m, msg, ok = sel.wait()
+			if !ok {
+				r.delivered()
+				return
+			}
+			// higher priority inputs could become ready while waiting, they go first
+			for {
+				hm, hmsg, ok := pickFirst(groups[:m.level])
+				if !ok {
+					break
+				}
+				r.forward(hm, hmsg)
+			}
+		}
+		r.forward(m, msg)
+	}
+}
+
+// forward deliver msg received from m unless it is a watermark
+func (r *Receiver) forward(m *member, msg *bytes.Buffer) {
+	if r.watermark(m.index, msg) {
+		return
+	}
+	r.deliver(msg)
+}
+
+func pickFirst(groups []*group) (*member, *bytes.Buffer, bool) {
+	for _, g := range groups {
+		if m, msg, ok := g.pick(); ok {
+			return m, msg, true
+		}
+	}
+	return nil, nil, false
+}
+
+// selector wait on all open members, its cases are built once
+// and members closed meanwhile are disabled
+type selector struct {
+	members []*member
+	cases   []reflect.SelectCase
+	open    int
+}
+
+func newSelector(all []*member) *selector {
+	s := &selector{members: all, cases: make([]reflect.SelectCase, len(all))}
+	for i, m := range all {
+		s.cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.in)}
+	}
+	s.open = len(all)
+	return s
+}
+
+// wait block until any open member has a message, it returns false
+// when all members are closed
+func (s *selector) wait() (*member, *bytes.Buffer, bool) {
+	for {
+		for i, m := range s.members {
+			// a zero Chan is ignored by reflect.Select
+			if m.closed && s.cases[i].Chan.IsValid() {
+				s.cases[i].Chan = reflect.Value{}
+				s.open--
+			}
+		}
+		if s.open == 0 {
+			return nil, nil, false
+		}
+		i, v, ok := reflect.Select(s.cases)
+		m := s.members[i]
+		if !ok {
+			m.close()
+			continue
+		}
+		if m.credits > 0 {
+			m.credits--
+		}
+		return m, v.Interface().(*bytes.Buffer), true
+	}
+}
@@ -0,0 +1,128 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+// filled returns a closed channel that contains count messages
+func filled(msg string, count int) <-chan *bytes.Buffer {
+	c := make(chan *bytes.Buffer, count)
+	for i := 0; i < count; i++ {
+		b := selina.GetBuffer()
+		b.WriteString(msg)
+		c <- b
+	}
+	close(c)
+	return c
+}
+
+func TestReceiverWatchWith(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   selina.EdgeOptions
+		na, nb int
+		want   string
+	}{
+		{
+			name: "weight",
+			a:    selina.EdgeOptions{Weight: 3},
+			b:    selina.EdgeOptions{Weight: 1},
+			na:   6,
+			nb:   4,
+			want: "AAABAAABBB",
+		},
+		{
+			name: "priority",
+			a:    selina.EdgeOptions{},
+			b:    selina.EdgeOptions{Priority: 1},
+			na:   3,
+			nb:   3,
+			want: "BBBAAA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &selina.Receiver{}
+			r.WatchWith(filled("A", tt.na), tt.a)
+			r.WatchWith(filled("B", tt.nb), tt.b)
+			var sb strings.Builder
+			for msg := range r.Receive() {
+				sb.WriteString(msg.String())
+				selina.FreeBuffer(msg)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Fatalf("Receive() order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeChainWithPriority(t *testing.T) {
+	control := selina.NewNode("control", &sliceReader{values: []string{"stop"}})
+	bulk := selina.NewNode("bulk", &sliceReader{values: []string{"1", "2", "3"}})
+	w := &sliceWriter{}
+	sink := selina.NewNode("sink", w)
+	control.ChainWith(sink, selina.EdgeOptions{Priority: 10})
+	bulk.Chain(sink)
+	p := selina.FreePipeline(control, bulk, sink)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	got := append([]string(nil), w.values...)
+	if len(got) != 4 {
+		t.Fatalf("Run() got = %v", got)
+	}
+	// bulk order is preserved
+	var data []string
+	for _, v := range got {
+		if v != "stop" {
+			data = append(data, v)
+		}
+	}
+	if !reflect.DeepEqual(data, []string{"1", "2", "3"}) {
+		t.Fatalf("Run() got = %v", got)
+	}
+}
+
+func TestReceiverPriorityAfterWait(t *testing.T) {
+	// with a single P receiver does not run between both sends below
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	for i := 0; i < 20; i++ {
+		low := make(chan *bytes.Buffer, 1)
+		high := make(chan *bytes.Buffer, 1)
+		r := &selina.Receiver{}
+		r.WatchWith(low, selina.EdgeOptions{})
+		r.WatchWith(high, selina.EdgeOptions{Priority: 1})
+		out := r.Receive()
+		// let receiver block waiting for any input
+		time.Sleep(time.Millisecond)
+		// receiver wakes with low, high is ready before low is delivered
+		for _, v := range []string{"low", "high"} {
+			b := selina.GetBuffer()
+			b.WriteString(v)
+			if v == "low" {
+				low <- b
+			} else {
+				high <- b
+			}
+		}
+		close(low)
+		close(high)
+		var got []string
+		for msg := range out {
+			got = append(got, msg.String())
+			selina.FreeBuffer(msg)
+		}
+		if want := []string{"high", "low"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("Receive() got = %v, want %v", got, want)
+		}
+	}
+}
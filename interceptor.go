@@ -0,0 +1,68 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+)
+
+// Direction of a message relative to node worker
+type Direction int
+
+const (
+	// Inbound message is going to be processed by worker
+	Inbound Direction = iota
+	// Outbound message was produced by worker
+	Outbound
+)
+
+func (d Direction) String() string {
+	if d == Inbound {
+		return "inbound"
+	}
+	return "outbound"
+}
+
+// MessageInfo describe an intercepted message
+type MessageInfo struct {
+	Node      string
+	ID        string
+	Direction Direction
+}
+
+// Interceptor see every message entering and leaving a worker,
+// msg must not be modified or retained (see Own), returning false
+// drops the message
+type Interceptor interface {
+	Intercept(ctx context.Context, info MessageInfo, msg *bytes.Buffer) bool
+}
+
+// InterceptorFunc allow to use a function as Interceptor
+type InterceptorFunc func(ctx context.Context, info MessageInfo, msg *bytes.Buffer) bool
+
+// Intercept implements Interceptor interface
+func (f InterceptorFunc) Intercept(ctx context.Context, info MessageInfo, msg *bytes.Buffer) bool {
+	return f(ctx, info, msg)
+}
+
+type interceptorsKey struct{}
+
+// WithInterceptors returns a context that carries ics, pass it to Pipeliner.Run
+// to intercept messages of all nodes, node interceptors run first
+func WithInterceptors(ctx context.Context, ics ...Interceptor) context.Context {
+	all := append(interceptorsFromContext(ctx), ics...)
+	return context.WithValue(ctx, interceptorsKey{}, all)
+}
+
+func interceptorsFromContext(ctx context.Context) []Interceptor {
+	ics, _ := ctx.Value(interceptorsKey{}).([]Interceptor)
+	return append([]Interceptor(nil), ics...)
+}
+
+func intercept(ctx context.Context, ics []Interceptor, info MessageInfo, msg *bytes.Buffer) bool {
+	for _, ic := range ics {
+		if !ic.Intercept(ctx, info, msg) {
+			return false
+		}
+	}
+	return true
+}
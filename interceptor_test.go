@@ -0,0 +1,77 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestNodeIntercept(t *testing.T) {
+	var mtx sync.Mutex
+	var calls []string
+	record := func(name string) selina.Interceptor {
+		return selina.InterceptorFunc(func(_ context.Context, info selina.MessageInfo, msg *bytes.Buffer) bool {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if info.Node == "mid" {
+				calls = append(calls, name+":"+info.Direction.String()+":"+msg.String())
+			}
+			return true
+		})
+	}
+	drop := selina.InterceptorFunc(func(_ context.Context, info selina.MessageInfo, msg *bytes.Buffer) bool {
+		return !(info.Direction == selina.Inbound && msg.String() == "2")
+	})
+	src := selina.NewNode("src", &sliceReader{values: []string{"1", "2", "3"}})
+	mid := selina.NewNode("mid", &dummyWorker{})
+	mid.Intercept(record("node"))
+	w := &sliceWriter{}
+	out := selina.NewNode("out", w)
+	src.Chain(mid).Chain(out)
+	p := selina.FreePipeline(src, mid, out)
+	ctx := selina.WithInterceptors(context.Background(), drop, record("pipeline"))
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if want := []string{"1", "3"}; !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() got = %v, want %v", w.values, want)
+	}
+	// interceptors run in goroutines so inbound and outbound may interleave
+	got := map[string]bool{}
+	for _, c := range calls {
+		got[c] = true
+	}
+	want := []string{
+		"node:inbound:1", "pipeline:inbound:1", "node:outbound:1", "pipeline:outbound:1",
+		"node:inbound:2",
+		"node:inbound:3", "pipeline:inbound:3", "node:outbound:3", "pipeline:outbound:3",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("interceptor calls = %v, want %v", calls, want)
+	}
+	for _, c := range want {
+		if !got[c] {
+			t.Fatalf("interceptor calls = %v, missing %s", calls, c)
+		}
+	}
+}
+
+func TestPipelineIntercept(t *testing.T) {
+	drop := selina.InterceptorFunc(func(_ context.Context, info selina.MessageInfo, msg *bytes.Buffer) bool {
+		return !(info.Direction == selina.Inbound && msg.String() == "2")
+	})
+	src := selina.NewNode("src", &sliceReader{values: []string{"1", "2", "3"}})
+	w := &sliceWriter{}
+	out := selina.NewNode("out", w)
+	p := selina.Intercept(selina.LinealPipeline(src, out), drop)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if want := []string{"1", "3"}; !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() got = %v, want %v", w.values, want)
+	}
+}
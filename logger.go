@@ -0,0 +1,63 @@
+package selina
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger is used by nodes and workers to report events,
+// args are alternating key value pairs as in log/slog
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	// With returns a Logger that always include args
+	With(args ...any) Logger
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, args ...any) { s.l.Debug(msg, args...) }
+func (s slogLogger) Info(msg string, args ...any)  { s.l.Info(msg, args...) }
+func (s slogLogger) Warn(msg string, args ...any)  { s.l.Warn(msg, args...) }
+func (s slogLogger) Error(msg string, args ...any) { s.l.Error(msg, args...) }
+func (s slogLogger) With(args ...any) Logger       { return slogLogger{l: s.l.With(args...)} }
+
+// NewSlogLogger adapts a *slog.Logger, nil means slog.Default()
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+func (n nopLogger) With(...any) Logger { return n }
+
+// NopLogger discard all messages, it is used when no Logger is configured
+var NopLogger Logger = nopLogger{}
+
+type loggerKey struct{}
+
+// WithLogger returns a context that carries l, pass it to Pipeliner.Run
+// to configure all nodes in a pipeline
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the Logger in ctx or NopLogger, inside Worker.Process
+// it is already bound to node name and id
+func LoggerFromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return NopLogger
+}
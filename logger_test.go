@@ -0,0 +1,124 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+type entry struct {
+	level string
+	msg   string
+	args  []any
+}
+
+// recorder keep all log entries in memory
+type recorder struct {
+	mtx     *sync.Mutex
+	entries *[]entry
+	with    []any
+}
+
+func newRecorder() recorder {
+	return recorder{mtx: &sync.Mutex{}, entries: &[]entry{}}
+}
+
+func (r recorder) add(level, msg string, args []any) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	*r.entries = append(*r.entries, entry{level: level, msg: msg, args: append(append([]any{}, r.with...), args...)})
+}
+
+func (r recorder) Debug(msg string, args ...any) { r.add("debug", msg, args) }
+func (r recorder) Info(msg string, args ...any)  { r.add("info", msg, args) }
+func (r recorder) Warn(msg string, args ...any)  { r.add("warn", msg, args) }
+func (r recorder) Error(msg string, args ...any) { r.add("error", msg, args) }
+func (r recorder) With(args ...any) selina.Logger {
+	r.with = append(append([]any{}, r.with...), args...)
+	return r
+}
+
+// find returns first entry with msg logged by node
+func (r recorder) find(msg string, node string) (entry, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, e := range *r.entries {
+		if e.msg == msg && e.has("node", node) {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+func (e entry) has(key string, value any) bool {
+	for i := 0; i+1 < len(e.args); i += 2 {
+		if e.args[i] == key && e.args[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNodeLogger(t *testing.T) {
+	rec := newRecorder()
+	w := selina.Map(func(ctx context.Context, in string) (string, error) {
+		selina.LoggerFromContext(ctx).Info("processing")
+		return in, nil
+	})
+	src := selina.NewNode("src", &sliceReader{values: []string{"1"}})
+	n := selina.NewNode("logged", w)
+	out := selina.NewNode("out", &sliceWriter{})
+	src.Chain(n).Chain(out)
+	p := selina.FreePipeline(src, n, out)
+	if err := p.Run(selina.WithLogger(context.Background(), rec)); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	for _, msg := range []string{"node started", "processing", "node stopped"} {
+		e, ok := rec.find(msg, "logged")
+		if !ok {
+			t.Fatalf("missing log entry %q", msg)
+		}
+		if !e.has("id", n.ID()) {
+			t.Fatalf("entry %q args = %v", msg, e.args)
+		}
+	}
+}
+
+func TestNodeSetLoggerFailure(t *testing.T) {
+	rec := newRecorder()
+	n := selina.NewNode("failing", selina.Map(func(context.Context, string) (string, error) {
+		return "", errors.New("broken")
+	}))
+	n.SetLogger(rec)
+	src := selina.NewNode("src", &sliceReader{values: []string{"1"}})
+	src.Chain(n)
+	p := selina.FreePipeline(src, n)
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatal("Run() err = nil")
+	}
+	e, ok := rec.find("node failed", "failing")
+	if !ok || e.level != "error" {
+		t.Fatalf("node failed entry = %+v, %v", e, ok)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	buff := &bytes.Buffer{}
+	l := selina.NewSlogLogger(slog.New(slog.NewTextHandler(buff, nil))).With("node", "A")
+	l.Info("hello", "count", 1)
+	got := buff.String()
+	for _, want := range []string{"msg=hello", "node=A", "count=1"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("slog output %q does not contain %q", got, want)
+		}
+	}
+	if selina.LoggerFromContext(context.Background()) != selina.NopLogger {
+		t.Fatal("LoggerFromContext() default is not NopLogger")
+	}
+}
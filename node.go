@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -19,6 +20,21 @@ type Stats struct {
 	SentBytes     int64
 	Received      int64
 	ReceivedBytes int64
+	// Skipped messages dropped by error policy or worker ErrorHandler
+	Skipped int64
+	// Retried count of retry attempts made by error policy
+	Retried int64
+	// Routed messages sent to error output
+	Routed int64
+	// Restarts times worker was restarted by RestartPolicy
+	Restarts int64
+	// Nested stats of inner nodes when worker is a PipelineWorker
+	Nested map[string]Stats
+	// OutstandingBytes bytes of messages sent by node and not freed yet,
+	// it is only accounted when pipeline runs with a memory budget (see WithMemoryBudget)
+	OutstandingBytes int64
+	// Watermark input watermark of node, zero if upstream does not emit watermarks
+	Watermark time.Time
 }
 
 // Node a node that can send and receive data
@@ -31,7 +47,21 @@ type Node struct {
 	close   chan struct{}
 	running bool
 	opMx    sync.RWMutex
-	chained map[string]struct{}
+	chained map[string]EdgeOptions
+	errs    errorState
+	errOut  Broadcaster
+	errNext map[string]struct{}
+	restart RestartPolicy
+	logger  Logger
+	// observers are notified about node events
+	observers []Observer
+	// interceptors run on every message in and out of worker
+	interceptors []Interceptor
+	// restarts is updated atomically
+	restarts int64
+	// outstanding is updated atomically
+	outstanding int64
+	wm          *watermark
 }
 
 // ID return a unique identifier for this node
@@ -55,15 +85,83 @@ func (n *Node) Running() bool {
 // it returns next node to be chained again
 // if next is already chained this operation does nothing
 func (n *Node) Chain(next *Node) *Node {
+	return n.ChainWith(next, EdgeOptions{})
+}
+
+// ChainWith is like Chain but configure priority and weight of this edge
+// in next node input (see EdgeOptions)
+func (n *Node) ChainWith(next *Node, opts EdgeOptions) *Node {
 	if n.IsChained(next) {
 		return next
 	}
-	c := n.output.Client()
+	c := n.output.ClientFor(next.ID())
+	next.input.WatchWith(c, opts)
+	n.chained[next.ID()] = opts
+	return next
+}
+
+// ShareOutput when enabled every chained node receive the same buffer
+// instead of a copy, downstream workers must not modify received messages
+// it must be called before Start
+func (n *Node) ShareOutput(shared bool) {
+	n.output.Shared = shared
+}
+
+// SetErrorPolicy configure how this node react to message errors
+// it must be called before Start
+func (n *Node) SetErrorPolicy(p ErrorPolicy) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	n.errs.policy = p
+	return nil
+}
+
+// SetLogger overrides pipeline Logger (see WithLogger) for this node,
+// it must be called before Start
+func (n *Node) SetLogger(l Logger) {
+	n.logger = l
+}
+
+// Observe register o to receive this node events,
+// it must be called before Start
+func (n *Node) Observe(o Observer) {
+	n.observers = append(n.observers, o)
+}
+
+// Intercept add interceptors for messages that enter and leave this node worker,
+// they run before pipeline interceptors (see WithInterceptors), it must be called before Start
+func (n *Node) Intercept(ics ...Interceptor) {
+	n.interceptors = append(n.interceptors, ics...)
+}
+
+// SetRestartPolicy configure if worker must be started again when Process fails,
+// it must be called before Start
+func (n *Node) SetRestartPolicy(p RestartPolicy) {
+	n.restart = p
+}
+
+// ChainError send messages that failed with ErrorRoute policy to next node,
+// it returns next node to be chained again
+func (n *Node) ChainError(next *Node) *Node {
+	if _, ok := n.errNext[next.ID()]; ok {
+		return next
+	}
+	c := n.errOut.ClientFor(next.ID())
 	next.input.Watch(c)
-	n.chained[next.ID()] = struct{}{}
+	n.errNext[next.ID()] = struct{}{}
 	return next
 }
 
+// NextErrors returns nodes id chained with ChainError
+func (n *Node) NextErrors() []string {
+	ret := make([]string, 0, len(n.errNext))
+	for k := range n.errNext {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
 // Next returns nodes id chained to current node
 func (n *Node) Next() []string {
 	ret := make([]string, 0, len(n.chained))
@@ -119,19 +217,153 @@ func (n *Node) Start(ctx context.Context) error {
 	if err := n.checkStart(); err != nil {
 		return err
 	}
+	if err := n.errs.policy.checkOutputs(len(n.errNext)); err != nil {
+		return fmt.Errorf("%s : %w", n.name, err)
+	}
+	// durable edges stop reading its logs on cancellation
+	n.input.quit = ctx.Done()
+	if err := n.input.prepare(); err != nil {
+		return fmt.Errorf("%s : %w", n.name, err)
+	}
+	n.input.trackWatermark(n.wm)
 	inChan := n.input.Receive()
 	outChan := make(chan *bytes.Buffer)
-	go n.output.Broadcast(outChan)
-	defer safeCloseChan(outChan)
-	inCtx := newNodeContext(ctx, n.close)
-	args := ProcessArgs{Input: inChan, Output: outChan}
-	err := n.w.Process(inCtx, args)
+	if len(n.errNext) > 0 {
+		errChan := make(chan *bytes.Buffer)
+		n.errOut.quit = ctx.Done()
+		go n.errOut.Broadcast(errChan)
+		defer close(errChan)
+		n.errs.route = errChan
+	}
+	logger := n.logger
+	if logger == nil {
+		logger = LoggerFromContext(ctx)
+	}
+	logger = logger.With("node", n.name, "id", n.id)
+	inCtx := withErrorState(newNodeContext(WithLogger(ctx, logger), n.close), &n.errs)
+	inCtx = context.WithValue(inCtx, watermarkKey{}, n.wm)
+	if b := budgetFromContext(ctx); b != nil {
+		inCtx = withBudgetHandle(inCtx, &budgetHandle{budget: b, block: inChan == nil, bytes: &n.outstanding})
+	}
+	obs := append(append([]Observer(nil), n.observers...), observersFromContext(ctx)...)
+	ics := append(append([]Interceptor(nil), n.interceptors...), interceptorsFromContext(ctx)...)
+	inChan, out, stopTaps := n.wrapChannels(inCtx, obs, ics, inChan, outChan)
+	broadcasted := make(chan struct{})
+	// pipeline cancellation releases sends to nodes that no longer read
+	n.output.quit = ctx.Done()
+	go func() {
+		defer close(broadcasted)
+		n.output.Broadcast(outChan)
+	}()
+	logger.Info("node started")
+	notify(obs, NodeStarted{n.event()})
+	var err error
+	if !n.restart.enabled() {
+		err = n.process(inCtx, ProcessArgs{Input: inChan, Output: out})
+		// a worker that returns without closing its output must not block downstream
+		safeCloseChan(out)
+	} else {
+		for i := 0; ; i++ {
+			err = n.attempt(inCtx, inChan, out)
+			if !n.restart.allow(i, err) {
+				break
+			}
+			logger.Warn("node restarting", "attempt", i+1, "error", err)
+			if n.restart.wait(inCtx, i) != nil {
+				break
+			}
+			atomic.AddInt64(&n.restarts, 1)
+			notify(obs, NodeRestarted{NodeEvent: n.event(), Attempt: i + 1, Err: err})
+		}
+		close(out)
+	}
+	stopTaps()
+	if err == nil {
+		err = n.input.failure()
+	}
+	// without downstream nodes last messages are acknowledged by broadcaster
+	if len(n.chained) == 0 {
+		<-broadcasted
+	}
 	if err != nil {
-		return fmt.Errorf("%s : %w", n.name, err)
+		logFailure(logger, err)
+		err = fmt.Errorf("%s : %w", n.name, err)
+		notify(obs, NodeFinished{NodeEvent: n.event(), Err: err})
+		return err
 	}
+	logger.Info("node stopped")
+	notify(obs, NodeFinished{NodeEvent: n.event()})
 	return nil
 }
 
+func logFailure(logger Logger, err error) {
+	var perr *PanicError
+	switch {
+	case errors.As(err, &perr):
+		logger.Error("node panic", "error", err, "stack", string(perr.Stack))
+	case isContextError(err):
+		logger.Info("node canceled", "error", err)
+	default:
+		logger.Error("node failed", "error", err)
+	}
+}
+
+func (n *Node) event() NodeEvent {
+	return NodeEvent{Node: n.name, ID: n.id, Time: time.Now()}
+}
+
+// wrapChannels put taps on worker channels to run interceptors and emit FirstMessage
+// and InputClosed events, channels are returned as is if there is nothing to do.
+// Input watermark is forwarded by the first stage that reads worker output.
+// Returned func stops input tap and wait until output tap has forwarded all messages
+func (n *Node) wrapChannels(ctx context.Context, obs []Observer, ics []Interceptor, in <-chan *bytes.Buffer, out chan<- *bytes.Buffer) (<-chan *bytes.Buffer, chan<- *bytes.Buffer, func()) {
+	var stops []func()
+	// FirstMessage is emitted by input tap or by output tap when node has no upstream
+	each := func(d Direction, first bool) func(*bytes.Buffer) bool {
+		info := MessageInfo{Node: n.name, ID: n.id, Direction: d}
+		seen := !first || len(obs) == 0
+		return func(msg *bytes.Buffer) bool {
+			if !seen {
+				seen = true
+				notify(obs, FirstMessage{n.event()})
+			}
+			return intercept(ctx, ics, info, msg)
+		}
+	}
+	source := in == nil
+	if !source && (len(obs) > 0 || len(ics) > 0) {
+		tapped := make(chan *bytes.Buffer)
+		quit := make(chan struct{})
+		var closed func()
+		if len(obs) > 0 {
+			closed = func() { notify(obs, InputClosed{n.event()}) }
+		}
+		done := tap(in, tapped, quit, each(Inbound, true), closed, nil)
+		stops = append(stops, func() {
+			close(quit)
+			<-done
+		})
+		in = tapped
+	}
+	if (source && len(obs) > 0) || len(ics) > 0 {
+		var wm *watermark
+		if !source {
+			wm = n.wm
+		}
+		own := make(chan *bytes.Buffer)
+		done := tap(own, out, ctx.Done(), each(Outbound, source), nil, wm)
+		stops = append(stops, func() { <-done })
+		out = own
+	} else if !source {
+		n.output.wm = n.wm
+	}
+	return in, out, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
 // ErrStopNotStarted returned when Stop is called before Start method
 var ErrStopNotStarted = errors.New("stopping a not started worker")
 
@@ -151,7 +383,30 @@ func (n *Node) Stop() error {
 func (n *Node) Stats() Stats {
 	oc, ob := n.output.Stats()
 	ic, ib := n.input.Stats()
-	return Stats{Sent: oc, SentBytes: ob, Received: ic, ReceivedBytes: ib}
+	return Stats{Sent: oc, SentBytes: ob, Received: ic, ReceivedBytes: ib,
+		Skipped:          atomic.LoadInt64(&n.errs.skipped),
+		Retried:          atomic.LoadInt64(&n.errs.retried),
+		Routed:           atomic.LoadInt64(&n.errs.routed),
+		Restarts:         atomic.LoadInt64(&n.restarts),
+		Nested:           n.nested(),
+		OutstandingBytes: atomic.LoadInt64(&n.outstanding),
+		Watermark:        n.wm.time(),
+	}
+}
+
+// inner returns pipeline wrapped by worker or nil
+func (n *Node) inner() Pipeliner {
+	if pw, ok := n.w.(*PipelineWorker); ok {
+		return pw.Pipeline()
+	}
+	return nil
+}
+
+func (n *Node) nested() map[string]Stats {
+	if p := n.inner(); p != nil {
+		return p.Stats()
+	}
+	return nil
 }
 
 func getID() string {
@@ -164,7 +419,9 @@ func getID() string {
 func NewNode(name string, w Worker) *Node {
 	id := getID()
 	n := &Node{id: id, w: w, name: name}
-	n.chained = make(map[string]struct{})
+	n.chained = make(map[string]EdgeOptions)
+	n.errNext = make(map[string]struct{})
 	n.close = make(chan struct{})
+	n.wm = newWatermark()
 	return n
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -25,15 +26,18 @@ type SimplePipeline struct {
 }
 
 // Run init pipeline proccesing, return an error!= nil if any Node fail
+// observers in ctx (see WithObserver) receive a PipelineCompleted event at the end
 func (p *SimplePipeline) Run(ctx context.Context) error {
-	g, ctx := errgroup.WithContext(ctx)
+	g, gctx := errgroup.WithContext(ctx)
 	for _, n := range p.nodes {
 		node := n
 		g.Go(func() error {
-			return node.Start(ctx)
+			return node.Start(gctx)
 		})
 	}
-	return g.Wait()
+	err := g.Wait()
+	notify(observersFromContext(ctx), PipelineCompleted{Time: time.Now(), Err: err})
+	return err
 }
 
 // Stats returns a map with all nodes Stats object
@@ -60,11 +64,10 @@ func (p *SimplePipeline) Nodes() []*Node {
 func LinealPipeline(nodes ...*Node) Pipeliner {
 	p := &SimplePipeline{}
 	p.nodes = make(map[string]*Node)
-	for i := 1; i < len(nodes); i++ {
-		prev := nodes[i-1]
-		curr := nodes[i]
-		prev.Chain(curr)
-		p.nodes[prev.ID()] = prev
+	for i, curr := range nodes {
+		if i > 0 {
+			nodes[i-1].Chain(curr)
+		}
 		p.nodes[curr.ID()] = curr
 	}
 	return p
@@ -82,32 +85,92 @@ func FreePipeline(nodes ...*Node) Pipeliner {
 	return p
 }
 
+// Observe returns p with o registered to receive events of all nodes and
+// the pipeline itself, like calling Run with WithObserver
+func Observe(p Pipeliner, o Observer) Pipeliner {
+	return &contextPipeline{Pipeliner: p, with: func(ctx context.Context) context.Context {
+		return WithObserver(ctx, o)
+	}}
+}
+
+// Intercept returns p with interceptors for messages of all nodes, they run
+// after interceptors of Run context, like calling Run with WithInterceptors
+func Intercept(p Pipeliner, ics ...Interceptor) Pipeliner {
+	return &contextPipeline{Pipeliner: p, with: func(ctx context.Context) context.Context {
+		return WithInterceptors(ctx, ics...)
+	}}
+}
+
+// contextPipeline run a pipeline with a context modified by with
+type contextPipeline struct {
+	Pipeliner
+	with func(context.Context) context.Context
+}
+
+// Run implements Pipeliner interface
+func (p *contextPipeline) Run(ctx context.Context) error {
+	return p.Pipeliner.Run(p.with(ctx))
+}
+
 // Graph export current pipeline structure and stats to .dot notation
+// nodes that wrap a pipeline (see PipelineWorker) are rendered as clusters
 func Graph(p Pipeliner, w io.Writer) error {
-	st := p.Stats()
 	_, err := fmt.Fprintln(w, "digraph {\n\trankdir=LR;")
 	if err != nil {
 		return err
 	}
+	if err := writeGraph(p, w, "\t"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "}"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeGraph(p Pipeliner, w io.Writer, indent string) error {
+	st := p.Stats()
 	for _, n := range p.Nodes() {
-		_, err := fmt.Fprintf(w, "\tX%s[label=\"%s\"];\n", n.ID(), n.Name())
+		_, err := fmt.Fprintf(w, "%sX%s[label=\"%s\"];\n", indent, n.ID(), n.Name())
+		if err != nil {
+			return err
+		}
+		inner := n.inner()
+		if inner == nil {
+			continue
+		}
+		_, err = fmt.Fprintf(w, "%ssubgraph cluster_%s {\n%s\tlabel=\"%s\";\n", indent, n.ID(), indent, n.Name())
 		if err != nil {
 			return err
 		}
+		if err := writeGraph(inner, w, indent+"\t"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s}\n", indent); err != nil {
+			return err
+		}
 	}
 	for _, n := range p.Nodes() {
 		s := st[n.ID()]
 		next := n.Next()
 		bcount := bytesToHuman(float64(s.SentBytes))
 		for _, id := range next {
-			_, err := fmt.Fprintf(w, "\tX%s -> X%s [label=\"count=%d,bytes=%s\"];\n", n.ID(), id, s.Sent, bcount)
+			// durable edges are drawn bold
+			style := ""
+			if n.chained[id].Durable != nil {
+				style = "style=bold,"
+			}
+			_, err := fmt.Fprintf(w, "%sX%s -> X%s [%slabel=\"count=%d,bytes=%s\"];\n", indent, n.ID(), id, style, s.Sent, bcount)
+			if err != nil {
+				return err
+			}
+		}
+		for _, id := range n.NextErrors() {
+			_, err := fmt.Fprintf(w, "%sX%s -> X%s [style=dashed,label=\"errors=%d\"];\n", indent, n.ID(), id, s.Routed)
 			if err != nil {
 				return err
 			}
 		}
-	}
-	if _, err := fmt.Fprintln(w, "}"); err != nil {
-		return err
 	}
 	return nil
 }
@@ -0,0 +1,155 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorMode define how a node react when its worker fail to process a message
+type ErrorMode string
+
+// Available error modes
+const (
+	// ErrorFail abort Process, this is the default
+	ErrorFail ErrorMode = "fail"
+	// ErrorSkip drop failed message and continue
+	ErrorSkip ErrorMode = "skip"
+	// ErrorRetry process failed message again, if all retries fail Process is aborted
+	ErrorRetry ErrorMode = "retry"
+	// ErrorRoute send failed message to nodes chained with Node.ChainError
+	ErrorRoute ErrorMode = "route"
+)
+
+// ErrInvalidErrorPolicy is returned when an ErrorPolicy has invalid values
+var ErrInvalidErrorPolicy = errors.New("invalid error policy")
+
+// ErrorPolicy configure how a node handle message errors
+type ErrorPolicy struct {
+	Mode ErrorMode
+	// Retries how many times a failed message is processed again in ErrorRetry mode
+	Retries int
+	// Backoff wait before first retry, it is doubled on every attempt
+	Backoff time.Duration
+	// Clock time source of Backoff, default SystemClock
+	Clock Clock
+}
+
+// Check if a combination of options is valid
+func (p ErrorPolicy) Check() error {
+	switch p.Mode {
+	case "", ErrorFail, ErrorSkip, ErrorRoute:
+	case ErrorRetry:
+		if p.Retries <= 0 {
+			return fmt.Errorf("%w: retries must be greater than zero", ErrInvalidErrorPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode '%s'", ErrInvalidErrorPolicy, p.Mode)
+	}
+	if p.Backoff < 0 {
+		return fmt.Errorf("%w: negative backoff", ErrInvalidErrorPolicy)
+	}
+	return nil
+}
+
+// checkOutputs validate policy against the number of nodes chained with Node.ChainError
+func (p ErrorPolicy) checkOutputs(outputs int) error {
+	if p.Mode == ErrorRoute && outputs == 0 {
+		return fmt.Errorf("%w: route mode without error output (see Node.ChainError)", ErrInvalidErrorPolicy)
+	}
+	return nil
+}
+
+// errorState is shared between a node and its worker through context
+type errorState struct {
+	policy  ErrorPolicy
+	route   chan<- *bytes.Buffer
+	skipped int64
+	retried int64
+	routed  int64
+}
+
+type errorStateKey struct{}
+
+func withErrorState(ctx context.Context, st *errorState) context.Context {
+	return context.WithValue(ctx, errorStateKey{}, st)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (s *errorState) retry(ctx context.Context, fn func() error, err error) error {
+	logger := LoggerFromContext(ctx)
+	clock := s.policy.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	wait := s.policy.Backoff
+	for i := 0; i < s.policy.Retries; i++ {
+		logger.Info("retrying message", "attempt", i+1, "error", err)
+		if wait > 0 {
+			select {
+			case <-clock.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			wait *= 2
+		}
+		atomic.AddInt64(&s.retried, 1)
+		if err = fn(); err == nil || isContextError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *errorState) sendRoute(ctx context.Context, msg []byte) error {
+	if s.route == nil {
+		// a message without output is never counted as routed
+		return s.policy.checkOutputs(0)
+	}
+	atomic.AddInt64(&s.routed, 1)
+	b := GetBuffer()
+	b.Write(msg)
+	return SendContext(ctx, b, s.route)
+}
+
+// HandleMessage calls fn and apply the error policy of the node that runs current worker
+// msg is the failed message, it is copied before being routed so caller still owns it,
+// handler is the worker own ErrorHandler (it can be nil) when it returns true error is skipped.
+// A nil return value means that worker must continue with next message,
+// any other error must abort Process
+func HandleMessage(ctx context.Context, msg []byte, handler ErrorHandler, fn func() error) error {
+	err := fn()
+	if err == nil || isContextError(err) {
+		return err
+	}
+	st, _ := ctx.Value(errorStateKey{}).(*errorState)
+	if handler != nil && handler(err) {
+		if st != nil {
+			atomic.AddInt64(&st.skipped, 1)
+		}
+		LoggerFromContext(ctx).Debug("message skipped by handler", "error", err)
+		return nil
+	}
+	if st == nil {
+		return err
+	}
+	switch st.policy.Mode {
+	case ErrorSkip:
+		atomic.AddInt64(&st.skipped, 1)
+		LoggerFromContext(ctx).Warn("message skipped", "error", err)
+		return nil
+	case ErrorRetry:
+		return st.retry(ctx, fn, err)
+	case ErrorRoute:
+		LoggerFromContext(ctx).Warn("message routed to error output", "error", err)
+		return st.sendRoute(ctx, msg)
+	default:
+		return err
+	}
+}
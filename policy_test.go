@@ -0,0 +1,191 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/selinatest"
+)
+
+var errBadMessage = errors.New("bad message")
+
+// flaky fail "bad" messages failures times, a negative value fail forever
+func flaky(failures int) selina.MapFunc[string, string] {
+	return func(_ context.Context, in string) (string, error) {
+		if in == "bad" {
+			if failures < 0 || failures > 0 {
+				failures--
+				return "", errBadMessage
+			}
+		}
+		return in, nil
+	}
+}
+
+func runPolicy(t *testing.T, policy selina.ErrorPolicy, fn selina.MapFunc[string, string]) (selina.Stats, []string, []string, error) {
+	t.Helper()
+	r := &sliceReader{values: []string{"1", "bad", "3"}}
+	w := &sliceWriter{}
+	dead := &sliceWriter{}
+	src := selina.NewNode("src", r)
+	mid := selina.NewNode("mid", selina.Map(fn))
+	out := selina.NewNode("out", w)
+	dl := selina.NewNode("dead", dead)
+	if err := mid.SetErrorPolicy(policy); err != nil {
+		t.Fatalf("SetErrorPolicy() err = %v", err)
+	}
+	src.Chain(mid).Chain(out)
+	mid.ChainError(dl)
+	p := selina.FreePipeline(src, mid, out, dl)
+	err := p.Run(context.Background())
+	return mid.Stats(), w.values, dead.values, err
+}
+
+func TestErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   selina.ErrorPolicy
+		failures int
+		want     []string
+		dead     []string
+		stats    selina.Stats
+		wantErr  error
+	}{
+		{
+			name:     "fail",
+			policy:   selina.ErrorPolicy{},
+			failures: -1,
+			wantErr:  errBadMessage,
+		},
+		{
+			name:     "skip",
+			policy:   selina.ErrorPolicy{Mode: selina.ErrorSkip},
+			failures: -1,
+			want:     []string{"1", "3"},
+			stats:    selina.Stats{Skipped: 1},
+		},
+		{
+			name:     "retry success",
+			policy:   selina.ErrorPolicy{Mode: selina.ErrorRetry, Retries: 3},
+			failures: 2,
+			want:     []string{"1", "bad", "3"},
+			stats:    selina.Stats{Retried: 2},
+		},
+		{
+			name:     "retry exhausted",
+			policy:   selina.ErrorPolicy{Mode: selina.ErrorRetry, Retries: 2},
+			failures: -1,
+			wantErr:  errBadMessage,
+			stats:    selina.Stats{Retried: 2},
+		},
+		{
+			name:     "route",
+			policy:   selina.ErrorPolicy{Mode: selina.ErrorRoute},
+			failures: -1,
+			want:     []string{"1", "3"},
+			dead:     []string{"bad"},
+			stats:    selina.Stats{Routed: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, got, dead, err := runPolicy(t, tt.policy, flaky(tt.failures))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Run() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(dead, tt.dead) {
+				t.Fatalf("Run() dead = %v, want %v", dead, tt.dead)
+			}
+			if st.Skipped != tt.stats.Skipped || st.Retried != tt.stats.Retried || st.Routed != tt.stats.Routed {
+				t.Fatalf("Stats() = %+v, want %+v", st, tt.stats)
+			}
+		})
+	}
+}
+
+func TestErrorPolicyHandlerSkip(t *testing.T) {
+	w := selina.NewTypedWorker(selina.TypedOptions[string, string]{
+		Func:    flaky(-1),
+		Handler: func(error) bool { return true },
+	})
+	n := selina.NewNode("handler", w)
+	src := selina.NewNode("src", &sliceReader{values: []string{"bad", "2"}})
+	out := selina.NewNode("out", &sliceWriter{})
+	src.Chain(n).Chain(out)
+	if err := selina.FreePipeline(src, n, out).Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if st := n.Stats(); st.Skipped != 1 {
+		t.Fatalf("Stats().Skipped = %d", st.Skipped)
+	}
+}
+
+func TestErrorPolicyCheck(t *testing.T) {
+	invalid := []selina.ErrorPolicy{
+		{Mode: "ignore"},
+		{Mode: selina.ErrorRetry},
+		{Mode: selina.ErrorSkip, Backoff: -1},
+	}
+	for _, p := range invalid {
+		if err := p.Check(); !errors.Is(err, selina.ErrInvalidErrorPolicy) {
+			t.Fatalf("Check(%+v) err = %v", p, err)
+		}
+	}
+	if err := (selina.ErrorPolicy{Mode: selina.ErrorRetry, Retries: 1}).Check(); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
+
+func TestErrorPolicyRouteWithoutOutput(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{values: []string{"bad"}})
+	mid := selina.NewNode("mid", selina.Map(flaky(-1)))
+	if err := mid.SetErrorPolicy(selina.ErrorPolicy{Mode: selina.ErrorRoute}); err != nil {
+		t.Fatalf("SetErrorPolicy() err = %v", err)
+	}
+	src.Chain(mid)
+	err := selina.FreePipeline(src, mid).Run(context.Background())
+	if !errors.Is(err, selina.ErrInvalidErrorPolicy) {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if st := mid.Stats(); st.Routed != 0 {
+		t.Fatalf("Stats().Routed = %d", st.Routed)
+	}
+}
+
+func TestErrorPolicyRetryClock(t *testing.T) {
+	clock := selinatest.NewFakeClock(time.Unix(0, 0))
+	policy := selina.ErrorPolicy{Mode: selina.ErrorRetry, Retries: 2, Backoff: time.Hour, Clock: clock}
+	errC := make(chan error, 1)
+	var got []string
+	go func() {
+		st, values, _, err := runPolicy(t, policy, flaky(2))
+		if err == nil && st.Retried != 2 {
+			err = errors.New("two retries expected")
+		}
+		got = values
+		errC <- err
+	}()
+	// backoff is doubled on second retry
+	for _, d := range []time.Duration{time.Hour, 2 * time.Hour} {
+		clock.BlockUntil(1)
+		clock.Advance(d)
+	}
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatalf("Run() err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retries did not use Clock")
+	}
+	if want := []string{"1", "bad", "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() got = %v, want %v", got, want)
+	}
+}
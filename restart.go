@@ -0,0 +1,102 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is returned by Node.Start when Worker.Process panics
+type PanicError struct {
+	Node  string
+	ID    string
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("node %s (%s) panic: %v", p.Node, p.ID, p.Value)
+}
+
+// Unwrap returns panic value if it is an error
+func (p *PanicError) Unwrap() error {
+	if err, ok := p.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// RestartPolicy configure if a node must call Worker.Process again when it fails
+type RestartPolicy struct {
+	// MaxRestarts zero never restarts, a negative value restarts forever
+	MaxRestarts int
+	// Backoff wait before first restart, it is doubled on every attempt
+	Backoff time.Duration
+	// Restart returns true if worker must be restarted after err,
+	// default restarts on any error but context cancellation
+	Restart func(err error) bool
+}
+
+func (r RestartPolicy) enabled() bool {
+	return r.MaxRestarts != 0
+}
+
+func (r RestartPolicy) allow(attempt int, err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+	if r.MaxRestarts >= 0 && attempt >= r.MaxRestarts {
+		return false
+	}
+	if r.Restart != nil {
+		return r.Restart(err)
+	}
+	return true
+}
+
+func (r RestartPolicy) wait(ctx context.Context, attempt int) error {
+	if r.Backoff <= 0 {
+		return ctx.Err()
+	}
+	const maxShift = 16
+	if attempt > maxShift {
+		attempt = maxShift
+	}
+	select {
+	case <-time.After(r.Backoff << attempt):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// process call Worker.Process recovering panics, output is always closed on return
+func (n *Node) process(ctx context.Context, args ProcessArgs) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Node: n.name, ID: n.id, Value: r, Stack: debug.Stack()}
+		}
+		safeCloseChan(args.Output)
+	}()
+	return n.w.Process(ctx, args)
+}
+
+// attempt run worker with its own output channel, forwarded into out
+// so out remains open between restarts
+func (n *Node) attempt(ctx context.Context, in <-chan *bytes.Buffer, out chan<- *bytes.Buffer) error {
+	own := make(chan *bytes.Buffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range own {
+			if err := SendContext(ctx, msg, out); err != nil {
+				FreeBuffer(msg)
+			}
+		}
+	}()
+	err := n.process(ctx, ProcessArgs{Input: in, Output: own})
+	<-done
+	return err
+}
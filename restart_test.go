@@ -0,0 +1,116 @@
+package selina_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+// panicOn panics the first times that msg is received
+func panicOn(msg string, times int) *selina.TypedWorker[string, string] {
+	return selina.Map(func(_ context.Context, in string) (string, error) {
+		if in == msg && times > 0 {
+			times--
+			panic("boom")
+		}
+		return in, nil
+	})
+}
+
+func TestNodePanicRecovered(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{values: []string{"1", "boom", "3"}})
+	bad := selina.NewNode("bad", panicOn("boom", 1))
+	w := &sliceWriter{}
+	out := selina.NewNode("out", w)
+	src.Chain(bad).Chain(out)
+	go func() { _ = src.Start(context.Background()) }()
+	errC := make(chan error, 1)
+	go func() { errC <- out.Start(context.Background()) }()
+	err := bad.Start(context.Background())
+	var perr *selina.PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Start() err = %v", err)
+	}
+	if perr.Node != "bad" || perr.ID != bad.ID() || perr.Value != "boom" {
+		t.Fatalf("PanicError = %+v", perr)
+	}
+	if !strings.Contains(string(perr.Stack), "panicOn") {
+		t.Fatalf("PanicError.Stack does not contain panic site: %s", perr.Stack)
+	}
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatalf("downstream Start() err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("downstream node not terminated")
+	}
+	if !reflect.DeepEqual(w.values, []string{"1"}) {
+		t.Fatalf("downstream got = %v", w.values)
+	}
+}
+
+func TestNodeRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   selina.RestartPolicy
+		panics   int
+		want     []string
+		restarts int64
+		wantErr  bool
+	}{
+		{
+			name:     "restart",
+			policy:   selina.RestartPolicy{MaxRestarts: 2},
+			panics:   1,
+			want:     []string{"1", "boom", "3"},
+			restarts: 1,
+		},
+		{
+			name:    "filtered",
+			policy:  selina.RestartPolicy{MaxRestarts: 2, Restart: func(error) bool { return false }},
+			panics:  1,
+			want:    []string{"1"},
+			wantErr: true,
+		},
+		{
+			name:     "exhausted",
+			policy:   selina.RestartPolicy{MaxRestarts: 1, Backoff: time.Millisecond},
+			panics:   2,
+			want:     []string{"1"},
+			restarts: 1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := selina.NewNode("src", &sliceReader{values: []string{"1", "boom", "boom", "3"}})
+			bad := selina.NewNode("bad", panicOn("boom", tt.panics))
+			bad.SetRestartPolicy(tt.policy)
+			w := &sliceWriter{}
+			out := selina.NewNode("out", w)
+			src.Chain(bad).Chain(out)
+			go func() { _ = src.Start(context.Background()) }()
+			errC := make(chan error, 1)
+			go func() { errC <- out.Start(context.Background()) }()
+			err := bad.Start(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() err = %v", err)
+			}
+			if err := <-errC; err != nil {
+				t.Fatalf("downstream Start() err = %v", err)
+			}
+			if !reflect.DeepEqual(w.values, tt.want) {
+				t.Fatalf("downstream got = %v, want %v", w.values, tt.want)
+			}
+			if st := bad.Stats(); st.Restarts != tt.restarts {
+				t.Fatalf("Stats().Restarts = %d, want %d", st.Restarts, tt.restarts)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package selinatest utilities to test selina workers
+package selinatest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+var _ selina.Clock = (*FakeClock)(nil)
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// FakeClock is a selina.Clock that only moves when Advance or Set are called
+type FakeClock struct {
+	mx      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+// NewFakeClock create a FakeClock that starts at now
+func NewFakeClock(now time.Time) *FakeClock {
+	f := &FakeClock{now: now}
+	f.cond = sync.NewCond(&f.mx)
+	return f
+}
+
+// Now implements selina.Clock
+func (f *FakeClock) Now() time.Time {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.now
+}
+
+// After implements selina.Clock, returned channel receive a value when
+// clock is advanced beyond d
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.waiters = append(f.waiters, waiter{at: f.now.Add(d), c: c})
+	f.cond.Broadcast()
+	return c
+}
+
+// Advance move clock forward and fire all expired waiters
+func (f *FakeClock) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set move clock to t and fire all expired waiters
+func (f *FakeClock) Set(t time.Time) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.now = t
+	sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- t
+	}
+	f.waiters = pending
+}
+
+// BlockUntil wait until at least n goroutines are waiting on After
+// it allows to advance clock only when workers are ready
+func (f *FakeClock) BlockUntil(n int) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Waiters returns how many channels are waiting for the clock
+func (f *FakeClock) Waiters() int {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return len(f.waiters)
+}
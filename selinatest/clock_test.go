@@ -0,0 +1,54 @@
+package selinatest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina/selinatest"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := selinatest.NewFakeClock(start)
+	short := c.After(time.Second)
+	long := c.After(time.Minute)
+	c.Advance(time.Second * 30)
+	select {
+	case got := <-short:
+		if !got.Equal(start.Add(time.Second * 30)) {
+			t.Fatalf("After() got = %v", got)
+		}
+	default:
+		t.Fatalf("After() not fired")
+	}
+	select {
+	case <-long:
+		t.Fatalf("After() fired too early")
+	default:
+	}
+	if c.Waiters() != 1 {
+		t.Fatalf("Waiters() = %d, want 1", c.Waiters())
+	}
+	c.Advance(time.Minute)
+	<-long
+	if !c.Now().Equal(start.Add(time.Second * 90)) {
+		t.Fatalf("Now() = %v", c.Now())
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	c := selinatest.NewFakeClock(time.Now())
+	done := make(chan struct{})
+	go func() {
+		<-c.After(time.Hour)
+		close(done)
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Hour)
+	<-done
+}
+
+func TestFakeClockAfterZero(t *testing.T) {
+	c := selinatest.NewFakeClock(time.Now())
+	<-c.After(0)
+}
@@ -0,0 +1,95 @@
+package selina
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// bufferMeta keeps track of buffers with more than one owner
+// or observed by OnFree
+type bufferMeta struct {
+	refs int32
+	free func()
+}
+
+// metas, acks, charges and markers are keyed by buffer and released by
+// FreeBuffer, so every message must be freed by its last owner or they
+// are kept forever, see OnFree to verify it
+var metas sync.Map
+
+// metered is the number of buffers in metas, while it is zero
+// FreeBuffer does not look up metas
+var metered int64
+
+// meta returns metadata of b, it is created if b has no metadata
+func meta(b *bytes.Buffer) *bufferMeta {
+	m, loaded := metas.LoadOrStore(b, &bufferMeta{refs: 1})
+	if !loaded {
+		atomic.AddInt64(&metered, 1)
+	}
+	return m.(*bufferMeta)
+}
+
+// Retain add n owners to b, after this call b must be treated as immutable
+// and every owner must call FreeBuffer, only the last call return b to the pool
+func Retain(b *bytes.Buffer, n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+	atomic.AddInt32(&meta(b).refs, int32(n))
+}
+
+// IsShared returns true if b has more than one owner
+func IsShared(b *bytes.Buffer) bool {
+	if atomic.LoadInt64(&metered) == 0 {
+		return false
+	}
+	m, ok := metas.Load(b)
+	return ok && atomic.LoadInt32(&m.(*bufferMeta).refs) > 1
+}
+
+// Own returns a buffer that caller is allowed to modify,
+// if b is shared a private copy is returned and b is released
+func Own(b *bytes.Buffer) *bytes.Buffer {
+	if !IsShared(b) {
+		return b
+	}
+	cp := GetBuffer()
+	cp.Write(b.Bytes())
+	Forward(b, cp)
+	chargeCopy(b, cp)
+	FreeBuffer(b)
+	return cp
+}
+
+// OnFree calls fn once when b is returned to the pool by its last owner,
+// it must be called before b is sent, acceptance tests use it to verify
+// that workers free every message (see workers.ATProcessFreeBuffers)
+func OnFree(b *bytes.Buffer, fn func()) {
+	if b == nil || fn == nil {
+		return
+	}
+	meta(b).free = fn
+}
+
+// release drop a reference of b, returns true when b has no more owners
+func release(b *bytes.Buffer) bool {
+	if atomic.LoadInt64(&metered) == 0 {
+		return true
+	}
+	v, ok := metas.Load(b)
+	if !ok {
+		return true
+	}
+	m := v.(*bufferMeta)
+	if atomic.AddInt32(&m.refs, -1) > 0 {
+		return false
+	}
+	metas.Delete(b)
+	atomic.AddInt64(&metered, -1)
+	if m.free != nil {
+		m.free()
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package selina_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestRetainFreeBuffer(t *testing.T) {
+	b := selina.GetBuffer()
+	b.WriteString("shared")
+	selina.Retain(b, 2)
+	if !selina.IsShared(b) {
+		t.Fatalf("IsShared() = false after Retain")
+	}
+	selina.FreeBuffer(b)
+	selina.FreeBuffer(b)
+	if b.String() != "shared" {
+		t.Fatalf("FreeBuffer() reset a buffer that still has owners")
+	}
+	if selina.IsShared(b) {
+		t.Fatalf("IsShared() = true with a single owner")
+	}
+	selina.FreeBuffer(b)
+	if b.Len() != 0 {
+		t.Fatalf("FreeBuffer() does not reset last reference")
+	}
+}
+
+func TestOnFree(t *testing.T) {
+	b := selina.GetBuffer()
+	var freed int
+	selina.OnFree(b, func() { freed++ })
+	selina.Retain(b, 1)
+	selina.FreeBuffer(b)
+	if freed != 0 {
+		t.Fatalf("OnFree() called while buffer has owners")
+	}
+	selina.FreeBuffer(b)
+	if freed != 1 {
+		t.Fatalf("OnFree() called %d times, want 1", freed)
+	}
+	selina.FreeBuffer(selina.GetBuffer())
+	if freed != 1 {
+		t.Fatalf("OnFree() called for a reused buffer")
+	}
+}
+
+func TestOwn(t *testing.T) {
+	b := selina.GetBuffer()
+	b.WriteString("data")
+	if got := selina.Own(b); got != b {
+		t.Fatalf("Own() copy a not shared buffer")
+	}
+	selina.Retain(b, 1)
+	own := selina.Own(b)
+	if own == b || own.String() != "data" {
+		t.Fatalf("Own() = %p(%s) want a private copy", own, own.String())
+	}
+	if selina.IsShared(b) {
+		t.Fatalf("Own() does not release shared buffer")
+	}
+	selina.FreeBuffer(b)
+	selina.FreeBuffer(own)
+}
+
+func TestBroadcasterShared(t *testing.T) {
+	const clientCount = 3
+	b := selina.Broadcaster{Shared: true}
+	var out []<-chan *bytes.Buffer
+	for i := 0; i < clientCount; i++ {
+		out = append(out, b.Client())
+	}
+	msg := selina.GetBuffer()
+	msg.WriteString("foo")
+	in := selina.SliceAsChannelRaw([]*bytes.Buffer{msg}, true)
+	go b.Broadcast(in)
+	var wg sync.WaitGroup
+	got := make([]*bytes.Buffer, clientCount)
+	for i, c := range out {
+		wg.Add(1)
+		go func(i int, c <-chan *bytes.Buffer) {
+			defer wg.Done()
+			for m := range c {
+				got[i] = m
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	for i := range got {
+		if got[i] != msg || got[i].String() != "foo" {
+			t.Fatalf("Broadcast() client %d got = %p, want = %p", i, got[i], msg)
+		}
+		selina.FreeBuffer(got[i])
+	}
+	if msg.Len() != 0 {
+		t.Fatalf("Broadcast() buffer not released after all clients free it")
+	}
+}
+
+func BenchmarkFreeBuffer(b *testing.B) {
+	for _, mode := range []string{"unshared", "shared"} {
+		b.Run(mode, func(b *testing.B) {
+			if mode == "shared" {
+				// a live shared buffer forces lookups of every freed buffer
+				live := selina.GetBuffer()
+				selina.Retain(live, 1)
+				defer func() {
+					selina.FreeBuffer(live)
+					selina.FreeBuffer(live)
+				}()
+			}
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					selina.FreeBuffer(selina.GetBuffer())
+				}
+			})
+		})
+	}
+}
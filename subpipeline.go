@@ -0,0 +1,131 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+var _ Worker = (*PipelineWorker)(nil)
+
+// ErrNilEntryExit is returned by PipelineWorker when entry or exit node is nil
+var ErrNilEntryExit = errors.New("nil entry or exit node in PipelineWorker")
+
+// ErrPipelineRestart is returned when Process of a PipelineWorker created
+// by NewPipelineWorker is called again (i.e. by a RestartPolicy)
+var ErrPipelineRestart = errors.New("pipeline already run, use NewPipelineWorkerBuilder to restart it")
+
+// PipelineBuilder returns a new pipeline with its entry and exit nodes
+type PipelineBuilder func() (p Pipeliner, entry, exit *Node, err error)
+
+// PipelineWorker run a whole pipeline as a single worker, messages received
+// by Process are sent to entry node and messages emitted by exit node are
+// sent to Process output. Like any Node a pipeline can run only once, so a
+// PipelineWorker can be restarted only when it has a PipelineBuilder
+type PipelineWorker struct {
+	mtx     sync.Mutex
+	p       Pipeliner
+	entry   *Node
+	exit    *Node
+	build   PipelineBuilder
+	started bool
+}
+
+// Pipeline returns wrapped pipeline, the last one built when it was restarted
+func (w *PipelineWorker) Pipeline() Pipeliner {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.p
+}
+
+// next returns the pipeline to run, a new one is built when current one already run
+func (w *PipelineWorker) next() (Pipeliner, *Node, *Node, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.started {
+		if w.build == nil {
+			return nil, nil, nil, ErrPipelineRestart
+		}
+		p, entry, exit, err := w.build()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		w.p, w.entry, w.exit = p, entry, exit
+	}
+	w.started = true
+	return w.p, w.entry, w.exit, nil
+}
+
+// Process implements Worker interface
+func (w *PipelineWorker) Process(ctx context.Context, args ProcessArgs) error {
+	defer close(args.Output)
+	p, entry, exit, err := w.next()
+	if err != nil {
+		return err
+	}
+	if entry == nil || exit == nil {
+		return ErrNilEntryExit
+	}
+	done := make(chan struct{})
+	defer close(done)
+	if args.Input != nil {
+		in := make(chan *bytes.Buffer)
+		entry.input.Watch(in)
+		go bridge(args.Input, in, done)
+	}
+	out := exit.output.Client()
+	// inner nodes do not inherit logger, error policy or observers of this
+	// node, only its cancellation
+	inner, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer context.AfterFunc(ctx, cancel)()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- p.Run(inner)
+	}()
+	for msg := range out {
+		// keep reading so inner pipeline can finish on cancellation
+		if err := SendContext(ctx, msg, args.Output); err != nil {
+			FreeBuffer(msg)
+		}
+	}
+	return <-errC
+}
+
+// bridge copy input into in until done, input is not watched directly by entry
+// node so a restarted PipelineWorker does not share it with a finished pipeline
+func bridge(input <-chan *bytes.Buffer, in chan<- *bytes.Buffer, done <-chan struct{}) {
+	defer close(in)
+	for {
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				return
+			}
+			select {
+			case in <- msg:
+			case <-done:
+				FreeBuffer(msg)
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// NewPipelineWorker create a worker that wraps p, entry and exit must be nodes of p
+func NewPipelineWorker(p Pipeliner, entry, exit *Node) *PipelineWorker {
+	return &PipelineWorker{p: p, entry: entry, exit: exit}
+}
+
+// NewPipelineWorkerBuilder create a worker that wraps the pipeline returned by build,
+// build is called again every time Process is restarted
+func NewPipelineWorkerBuilder(build PipelineBuilder) (*PipelineWorker, error) {
+	p, entry, exit, err := build()
+	if err != nil {
+		return nil, err
+	}
+	return &PipelineWorker{p: p, entry: entry, exit: exit, build: build}, nil
+}
@@ -0,0 +1,150 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+func TestPipelineWorker(t *testing.T) {
+	first := selina.NewNode("first", &dummyWorker{})
+	upper := selina.NewNode("upper", selina.Map(func(_ context.Context, in string) (string, error) {
+		return strings.ToUpper(in), nil
+	}))
+	inner := selina.LinealPipeline(first, upper)
+	src := selina.NewNode("src", &sliceReader{values: []string{"a", "b"}})
+	sub := selina.NewNode("sub", selina.NewPipelineWorker(inner, first, upper))
+	w := &sliceWriter{}
+	sink := selina.NewNode("sink", w)
+	p := selina.LinealPipeline(src, sub, sink)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if want := []string{"A", "B"}; !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() got = %v, want %v", w.values, want)
+	}
+	nested := p.Stats()[sub.ID()].Nested
+	if len(nested) != 2 || nested[upper.ID()].Sent != 2 {
+		t.Fatalf("Stats().Nested = %+v", nested)
+	}
+	buff := &bytes.Buffer{}
+	if err := selina.Graph(p, buff); err != nil {
+		t.Fatalf("Graph() err = %v", err)
+	}
+	for _, want := range []string{"subgraph cluster_" + sub.ID(), "X" + first.ID() + " -> X" + upper.ID()} {
+		if !strings.Contains(buff.String(), want) {
+			t.Fatalf("Graph() = %s, missing %s", buff.String(), want)
+		}
+	}
+}
+
+func TestPipelineWorkerErrorPolicy(t *testing.T) {
+	first := selina.NewNode("first", &dummyWorker{})
+	check := selina.NewNode("check", selina.Map(func(_ context.Context, in string) (string, error) {
+		if in == "b" {
+			return "", errors.New("invalid b")
+		}
+		return in, nil
+	}))
+	src := selina.NewNode("src", &sliceReader{values: []string{"a", "b"}})
+	sub := selina.NewNode("sub", selina.NewPipelineWorker(selina.LinealPipeline(first, check), first, check))
+	// policy of outer node does not apply to inner nodes
+	if err := sub.SetErrorPolicy(selina.ErrorPolicy{Mode: selina.ErrorSkip}); err != nil {
+		t.Fatal(err)
+	}
+	sink := selina.NewNode("sink", &sliceWriter{})
+	p := selina.LinealPipeline(src, sub, sink)
+	if err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid b") {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if st := p.Stats()[sub.ID()]; st.Skipped != 0 {
+		t.Fatalf("outer Skipped = %d", st.Skipped)
+	}
+}
+
+func TestPipelineWorkerNilEntry(t *testing.T) {
+	pw := selina.NewPipelineWorker(selina.FreePipeline(), nil, nil)
+	err := pw.Process(context.Background(), selina.ProcessArgs{Output: make(chan *bytes.Buffer)})
+	if !errors.Is(err, selina.ErrNilEntryExit) {
+		t.Fatalf("Process() err = %v", err)
+	}
+}
+
+// failWorker returns err without reading input
+type failWorker struct{ err error }
+
+func (f failWorker) Process(_ context.Context, args selina.ProcessArgs) error {
+	close(args.Output)
+	return f.err
+}
+
+// gatedReader send values after gate is closed
+type gatedReader struct {
+	gate   chan struct{}
+	values []string
+}
+
+func (r *gatedReader) Process(ctx context.Context, args selina.ProcessArgs) error {
+	select {
+	case <-r.gate:
+	case <-ctx.Done():
+		close(args.Output)
+		return ctx.Err()
+	}
+	return (&sliceReader{values: r.values}).Process(ctx, args)
+}
+
+func TestPipelineWorkerRestart(t *testing.T) {
+	boom := errors.New("boom")
+	gate := make(chan struct{})
+	builds := 0
+	pw, err := selina.NewPipelineWorkerBuilder(func() (selina.Pipeliner, *selina.Node, *selina.Node, error) {
+		builds++
+		if builds == 1 {
+			bad := selina.NewNode("bad", failWorker{err: boom})
+			return selina.FreePipeline(bad), bad, bad, nil
+		}
+		// restarted, outer source can send now
+		close(gate)
+		upper := selina.NewNode("upper", selina.Map(func(_ context.Context, in string) (string, error) {
+			return strings.ToUpper(in), nil
+		}))
+		return selina.FreePipeline(upper), upper, upper, nil
+	})
+	if err != nil {
+		t.Fatalf("NewPipelineWorkerBuilder() err = %v", err)
+	}
+	src := selina.NewNode("src", &gatedReader{gate: gate, values: []string{"a", "b"}})
+	sub := selina.NewNode("sub", pw)
+	sub.SetRestartPolicy(selina.RestartPolicy{MaxRestarts: 1})
+	w := &sliceWriter{}
+	sink := selina.NewNode("sink", w)
+	if err := selina.LinealPipeline(src, sub, sink).Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if want := []string{"A", "B"}; !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() got = %v, want %v", w.values, want)
+	}
+	if st := sub.Stats(); st.Restarts != 1 || len(st.Nested) != 1 {
+		t.Fatalf("Stats() = %+v", st)
+	}
+}
+
+func TestPipelineWorkerNoBuilder(t *testing.T) {
+	n := selina.NewNode("n", &dummyWorker{})
+	pw := selina.NewPipelineWorker(selina.FreePipeline(n), n, n)
+	in := make(chan *bytes.Buffer)
+	close(in)
+	if err := pw.Process(context.Background(), selina.ProcessArgs{Input: in, Output: make(chan *bytes.Buffer)}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	err := pw.Process(context.Background(), selina.ProcessArgs{Output: make(chan *bytes.Buffer)})
+	if !errors.Is(err, selina.ErrPipelineRestart) {
+		t.Fatalf("second Process() err = %v", err)
+	}
+}
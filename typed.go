@@ -0,0 +1,160 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MapFunc transform a decoded message into a new value
+// returning ErrSkipMessage drops current message without aborting Process
+type MapFunc[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+var (
+	// ErrSkipMessage can be returned by a MapFunc to filter a message
+	ErrSkipMessage = errors.New("skip message")
+	// ErrNilMapFunc a nil MapFunc is provided via TypedOptions
+	ErrNilMapFunc = errors.New("nil MapFunc passed to TypedWorker")
+)
+
+// TypedOptions customize a TypedWorker
+type TypedOptions[In, Out any] struct {
+	// Func is called once per message
+	Func MapFunc[In, Out]
+	// ReadFormat decode messages into In, default is json.Unmarshal
+	// if In is []byte or string and ReadFormat is nil raw message is used
+	ReadFormat Unmarshaler
+	// WriteFormat encode Out values, default is json.Marshal
+	// if Out is []byte or string and WriteFormat is nil value is written as is
+	WriteFormat Marshaler
+	// Handler is called on decode, Func and encode errors before node ErrorPolicy
+	Handler ErrorHandler
+}
+
+// Check if a combination of options is valid
+func (o TypedOptions[In, Out]) Check() error {
+	if o.Func == nil {
+		return ErrNilMapFunc
+	}
+	return nil
+}
+
+var _ Worker = (*TypedWorker[any, any])(nil)
+
+// TypedWorker decode every message into In, call Func and encode its result
+// so users can write transformations without dealing with *bytes.Buffer
+type TypedWorker[In, Out any] struct {
+	opts TypedOptions[In, Out]
+}
+
+func (t *TypedWorker[In, Out]) decode(data []byte) (In, error) {
+	var in In
+	if t.opts.ReadFormat == nil {
+		switch v := any(&in).(type) {
+		case *[]byte:
+			*v = append([]byte(nil), data...)
+			return in, nil
+		case *string:
+			*v = string(data)
+			return in, nil
+		}
+		return in, DefaultUnmarshaler(data, &in)
+	}
+	return in, t.opts.ReadFormat(data, &in)
+}
+
+func (t *TypedWorker[In, Out]) encode(out Out, msg *bytes.Buffer) error {
+	if t.opts.WriteFormat == nil {
+		switch v := any(out).(type) {
+		case []byte:
+			msg.Write(v)
+			return nil
+		case string:
+			msg.WriteString(v)
+			return nil
+		}
+	}
+	wf := DefaultMarshaler
+	if t.opts.WriteFormat != nil {
+		wf = t.opts.WriteFormat
+	}
+	data, err := wf(out)
+	if err != nil {
+		return fmt.Errorf("encoding %w", err)
+	}
+	msg.Write(data)
+	return nil
+}
+
+func (t *TypedWorker[In, Out]) transform(ctx context.Context, data []byte, msg *bytes.Buffer) error {
+	in, err := t.decode(data)
+	if err != nil {
+		return fmt.Errorf("decoding %w", err)
+	}
+	out, err := t.opts.Func(ctx, in)
+	if err != nil {
+		return err
+	}
+	return t.encode(out, msg)
+}
+
+// Process implements Worker interface
+func (t *TypedWorker[In, Out]) Process(ctx context.Context, args ProcessArgs) error {
+	defer close(args.Output)
+	if err := t.opts.Check(); err != nil {
+		return err
+	}
+	if args.Input == nil {
+		return ErrNilUpstream
+	}
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			var skip, filtered bool
+			out := GetBuffer()
+			err := HandleMessage(ctx, msg.Bytes(), t.opts.Handler, func() error {
+				out.Reset()
+				err := t.transform(ctx, msg.Bytes(), out)
+				if errors.Is(err, ErrSkipMessage) {
+					skip, filtered = true, true
+					return nil
+				}
+				skip = err != nil
+				return err
+			})
+			switch {
+			case filtered:
+				Ack(msg, nil)
+			case err == nil && !skip:
+				Forward(msg, out)
+			}
+			FreeBuffer(msg)
+			if err != nil || skip {
+				FreeBuffer(out)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if err := SendContext(ctx, out, args.Output); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// NewTypedWorker create a TypedWorker with given options
+func NewTypedWorker[In, Out any](opts TypedOptions[In, Out]) *TypedWorker[In, Out] {
+	return &TypedWorker[In, Out]{opts: opts}
+}
+
+// Map is a shortcut to create a TypedWorker with default codecs
+func Map[In, Out any](fn MapFunc[In, Out]) *TypedWorker[In, Out] {
+	return NewTypedWorker(TypedOptions[In, Out]{Func: fn})
+}
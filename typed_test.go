@@ -0,0 +1,144 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/licaonfee/selina"
+)
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestTypedWorkerProcess(t *testing.T) {
+	errBadAge := errors.New("bad age")
+	older := func(ctx context.Context, p person) (person, error) {
+		if p.Age < 0 {
+			return p, errBadAge
+		}
+		if p.Age == 0 {
+			return p, selina.ErrSkipMessage
+		}
+		p.Age++
+		return p, nil
+	}
+	tests := []struct {
+		name    string
+		w       selina.Worker
+		in      []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "json to json",
+			w:    selina.Map(older),
+			in:   []string{`{"name":"Selina","age":1}`, `{"name":"Lizbeth","age":2}`},
+			want: []string{`{"name":"Selina","age":2}`, `{"name":"Lizbeth","age":3}`},
+		},
+		{
+			name: "skip message",
+			w:    selina.Map(older),
+			in:   []string{`{"name":"Selina","age":0}`, `{"name":"Lizbeth","age":2}`},
+			want: []string{`{"name":"Lizbeth","age":3}`},
+		},
+		{
+			name:    "func error",
+			w:       selina.Map(older),
+			in:      []string{`{"name":"Selina","age":-1}`, `{"name":"Lizbeth","age":2}`},
+			want:    []string{},
+			wantErr: errBadAge,
+		},
+		{
+			name: "func error handled",
+			w: selina.NewTypedWorker(selina.TypedOptions[person, person]{
+				Func:    older,
+				Handler: func(error) bool { return true },
+			}),
+			in:   []string{`{"name":"Selina","age":-1}`, `{"name":"Lizbeth","age":2}`},
+			want: []string{`{"name":"Lizbeth","age":3}`},
+		},
+		{
+			name:    "decode error",
+			w:       selina.Map(older),
+			in:      []string{`{"name":"Selina","age":`},
+			want:    []string{},
+			wantErr: &json.SyntaxError{},
+		},
+		{
+			name: "raw to typed",
+			w: selina.Map(func(ctx context.Context, in string) (person, error) {
+				return person{Name: in}, nil
+			}),
+			in:   []string{"Selina"},
+			want: []string{`{"name":"Selina","age":0}`},
+		},
+		{
+			name: "typed to raw",
+			w: selina.Map(func(ctx context.Context, p person) ([]byte, error) {
+				return []byte(strings.ToUpper(p.Name)), nil
+			}),
+			in:   []string{`{"name":"Selina","age":1}`},
+			want: []string{"SELINA"},
+		},
+		{
+			name:    "nil func",
+			w:       selina.NewTypedWorker(selina.TypedOptions[string, string]{}),
+			in:      []string{},
+			want:    []string{},
+			wantErr: selina.ErrNilMapFunc,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := selina.SliceAsChannelOfBuffer(tt.in, true)
+			output := make(chan *bytes.Buffer, len(tt.in))
+			args := selina.ProcessArgs{Input: input, Output: output}
+			err := tt.w.Process(context.Background(), args)
+			var se *json.SyntaxError
+			if errors.As(tt.wantErr, &se) {
+				if !errors.As(err, &se) {
+					t.Fatalf("Process() err = %v, wantErr = %T", err, tt.wantErr)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process() err = %v, wantErr = %v", err, tt.wantErr)
+			}
+			got := []string{}
+			for _, b := range selina.ChannelAsSlice(output) {
+				got = append(got, b.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Process() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypedWorkerNilUpstream(t *testing.T) {
+	w := selina.Map(func(ctx context.Context, in string) (string, error) { return in, nil })
+	output := make(chan *bytes.Buffer)
+	args := selina.ProcessArgs{Input: nil, Output: output}
+	if err := w.Process(context.Background(), args); !errors.Is(err, selina.ErrNilUpstream) {
+		t.Fatalf("Process() err = %v", err)
+	}
+}
+
+func TestTypedWorkerInPipeline(t *testing.T) {
+	r := &sliceReader{values: []string{"1", "2", "3"}}
+	w := &sliceWriter{}
+	double := selina.Map(func(ctx context.Context, in int) (int, error) { return in * 2, nil })
+	p := selina.LinealPipeline(selina.NewNode("read", r), selina.NewNode("double", double), selina.NewNode("write", w))
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	want := []string{"2", "4", "6"}
+	if !reflect.DeepEqual(w.values, want) {
+		t.Fatalf("Run() got = %v, want = %v", w.values, want)
+	}
+}
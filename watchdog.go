@@ -0,0 +1,188 @@
+package selina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Blocking describe why a node is not making progress
+type Blocking string
+
+const (
+	// BlockedSend worker output can not be delivered to a downstream node
+	BlockedSend Blocking = "send"
+	// BlockedReceive upstream nodes can not deliver messages because worker
+	// is not reading its input
+	BlockedReceive Blocking = "receive"
+)
+
+// Stall is a node without progress for longer than WatchdogOptions.Timeout
+type Stall struct {
+	Node      string
+	ID        string
+	BlockedOn Blocking
+	// To is the node that does not accept messages when BlockedOn is BlockedSend
+	To   string
+	ToID string
+	For  time.Duration
+}
+
+// StallError is reported when one or more nodes are stalled,
+// Stalls contains every node blocked at that time
+type StallError struct {
+	Stalls []Stall
+}
+
+func (e *StallError) Error() string {
+	parts := make([]string, len(e.Stalls))
+	for i, s := range e.Stalls {
+		if s.BlockedOn == BlockedSend {
+			parts[i] = fmt.Sprintf("%s blocked on send to %s for %v", s.Node, s.To, s.For.Round(time.Millisecond))
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s blocked on receive for %v", s.Node, s.For.Round(time.Millisecond))
+	}
+	return "pipeline stalled: " + strings.Join(parts, ", ")
+}
+
+// WriteDOT export stalled edges as a wait-for graph in .dot notation,
+// an edge A -> B means A waits for B to read its messages, so a cycle is a deadlock
+func (e *StallError) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph {\n\trankdir=LR;"); err != nil {
+		return err
+	}
+	for _, s := range e.Stalls {
+		var err error
+		switch s.BlockedOn {
+		case BlockedSend:
+			_, err = fmt.Fprintf(w, "\tX%s[label=\"%s\"];\n\tX%s[label=\"%s\"];\n\tX%s -> X%s [label=\"%v\"];\n",
+				s.ID, s.Node, s.ToID, s.To, s.ID, s.ToID, s.For.Round(time.Millisecond))
+		default:
+			_, err = fmt.Fprintf(w, "\tX%s[label=\"%s\",style=dashed];\n", s.ID, s.Node)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// ErrInvalidWatchdog is returned when WatchdogOptions has invalid values
+var ErrInvalidWatchdog = errors.New("invalid watchdog options")
+
+// WatchdogOptions customize RunWithWatchdog
+type WatchdogOptions struct {
+	// Timeout how long a node can be blocked before it is reported
+	Timeout time.Duration
+	// Interval between checks, default Timeout/4
+	Interval time.Duration
+	// OnStall is called once every time pipeline becomes stalled
+	OnStall func(*StallError)
+	// Cancel stops the pipeline on stall, RunWithWatchdog returns *StallError
+	Cancel bool
+}
+
+// Check if a combination of options is valid
+func (o WatchdogOptions) Check() error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("%w: timeout must be greater than zero", ErrInvalidWatchdog)
+	}
+	if o.Interval < 0 {
+		return fmt.Errorf("%w: negative interval", ErrInvalidWatchdog)
+	}
+	return nil
+}
+
+// Stalls returns all nodes in p blocked at least timeout
+func Stalls(p Pipeliner, timeout time.Duration) []Stall {
+	now := time.Now()
+	nodes := p.Nodes()
+	names := make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		names[n.ID()] = n.Name()
+	}
+	var ret []Stall
+	for _, n := range nodes {
+		for _, b := range []*Broadcaster{&n.output, &n.errOut} {
+			if to, since, ok := b.Blocked(); ok && now.Sub(since) >= timeout {
+				ret = append(ret, Stall{Node: n.name, ID: n.id, BlockedOn: BlockedSend,
+					To: names[to], ToID: to, For: now.Sub(since)})
+			}
+		}
+		if since, ok := n.input.Pending(); ok && now.Sub(since) >= timeout {
+			ret = append(ret, Stall{Node: n.name, ID: n.id, BlockedOn: BlockedReceive, For: now.Sub(since)})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Node != ret[j].Node {
+			return ret[i].Node < ret[j].Node
+		}
+		return ret[i].BlockedOn < ret[j].BlockedOn
+	})
+	return ret
+}
+
+// RunWithWatchdog call p.Run and check periodically if nodes are stalled
+func RunWithWatchdog(ctx context.Context, p Pipeliner, opts WatchdogOptions) error {
+	if err := opts.Check(); err != nil {
+		return err
+	}
+	interval := opts.Interval
+	if interval == 0 {
+		interval = opts.Timeout / 4
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- p.Run(ctx)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var stalled *StallError
+	for {
+		select {
+		case err := <-errC:
+			return err
+		case <-ticker.C:
+			if stalled != nil {
+				if len(Stalls(p, opts.Timeout)) == 0 {
+					stalled = nil
+				}
+				continue
+			}
+			// edges of a deadlock block one after another, so once a node is
+			// stalled every blocked node is reported to keep the whole cycle
+			s := Stalls(p, 0)
+			if !stalledFor(s, opts.Timeout) {
+				continue
+			}
+			stalled = &StallError{Stalls: s}
+			LoggerFromContext(ctx).Error("pipeline stalled", "error", stalled)
+			if opts.OnStall != nil {
+				opts.OnStall(stalled)
+			}
+			if opts.Cancel {
+				cancel()
+				<-errC
+				return stalled
+			}
+		}
+	}
+}
+
+// stalledFor returns true if any stall in s lasts at least timeout
+func stalledFor(s []Stall, timeout time.Duration) bool {
+	for _, st := range s {
+		if st.For >= timeout {
+			return true
+		}
+	}
+	return false
+}
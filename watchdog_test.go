@@ -0,0 +1,104 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+)
+
+// stuckWorker never reads its input
+type stuckWorker struct{}
+
+func (s *stuckWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunWithWatchdogCycle(t *testing.T) {
+	values := make([]string, 50)
+	for i := range values {
+		values[i] = "ping"
+	}
+	src := selina.NewNode("src", &sliceReader{values: values})
+	a := selina.NewNode("A", &dummyWorker{})
+	b := selina.NewNode("B", &dummyWorker{})
+	src.Chain(a)
+	a.Chain(b)
+	b.Chain(a)
+	p := selina.FreePipeline(src, a, b)
+	var reported int
+	opts := selina.WatchdogOptions{
+		Timeout:  50 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		OnStall:  func(*selina.StallError) { reported++ },
+		Cancel:   true,
+	}
+	err := selina.RunWithWatchdog(context.Background(), p, opts)
+	var stall *selina.StallError
+	if !errors.As(err, &stall) {
+		t.Fatalf("RunWithWatchdog() err = %v", err)
+	}
+	if reported != 1 {
+		t.Fatalf("OnStall called %d times", reported)
+	}
+	edges := map[string]string{}
+	for _, s := range stall.Stalls {
+		if s.BlockedOn == selina.BlockedSend {
+			edges[s.Node] = s.To
+		}
+	}
+	if edges["A"] != "B" || edges["B"] != "A" {
+		t.Fatalf("stalled edges = %v", edges)
+	}
+	buff := &bytes.Buffer{}
+	if err := stall.WriteDOT(buff); err != nil {
+		t.Fatalf("WriteDOT() err = %v", err)
+	}
+	want := "X" + a.ID() + " -> X" + b.ID()
+	if !strings.Contains(buff.String(), want) {
+		t.Fatalf("WriteDOT() = %s, missing %s", buff.String(), want)
+	}
+	// cancelled sends are released, so no node keeps blocked
+	deadline := time.Now().Add(time.Second)
+	for len(selina.Stalls(p, 0)) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stalls() after cancel = %+v", selina.Stalls(p, 0))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunWithWatchdogReceive(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{values: []string{"1"}})
+	sink := selina.NewNode("sink", &stuckWorker{})
+	src.Chain(sink)
+	p := selina.FreePipeline(src, sink)
+	opts := selina.WatchdogOptions{Timeout: 30 * time.Millisecond, Cancel: true}
+	err := selina.RunWithWatchdog(context.Background(), p, opts)
+	var stall *selina.StallError
+	if !errors.As(err, &stall) {
+		t.Fatalf("RunWithWatchdog() err = %v", err)
+	}
+	if len(stall.Stalls) != 1 || stall.Stalls[0].Node != "sink" || stall.Stalls[0].BlockedOn != selina.BlockedReceive {
+		t.Fatalf("Stalls = %+v", stall.Stalls)
+	}
+}
+
+func TestRunWithWatchdogNoStall(t *testing.T) {
+	src := selina.NewNode("src", &sliceReader{values: []string{"1", "2"}})
+	out := selina.NewNode("out", &sliceWriter{})
+	src.Chain(out)
+	opts := selina.WatchdogOptions{Timeout: time.Second, Cancel: true}
+	if err := selina.RunWithWatchdog(context.Background(), selina.FreePipeline(src, out), opts); err != nil {
+		t.Fatalf("RunWithWatchdog() err = %v", err)
+	}
+	if err := selina.RunWithWatchdog(context.Background(), selina.FreePipeline(), selina.WatchdogOptions{}); !errors.Is(err, selina.ErrInvalidWatchdog) {
+		t.Fatalf("RunWithWatchdog() err = %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package selina
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// markers are internal messages that carry a watermark, they are never delivered to workers
+var (
+	markers sync.Map
+	// marked avoid a map lookup when watermarks are not used
+	marked int64
+)
+
+func newMarker(t int64) *bytes.Buffer {
+	b := GetBuffer()
+	markers.Store(b, t)
+	atomic.AddInt64(&marked, 1)
+	return b
+}
+
+// markerTime returns watermark carried by msg in unix nanoseconds
+func markerTime(msg *bytes.Buffer) (int64, bool) {
+	if atomic.LoadInt64(&marked) == 0 {
+		return 0, false
+	}
+	t, ok := markers.Load(msg)
+	if !ok {
+		return 0, false
+	}
+	return t.(int64), true
+}
+
+// IsWatermark returns the watermark carried by msg, workers never receive
+// them, it is useful to read output of a source outside a pipeline
+func IsWatermark(msg *bytes.Buffer) (time.Time, bool) {
+	t, ok := markerTime(msg)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, t), true
+}
+
+func isMarker(msg *bytes.Buffer) bool {
+	_, ok := markerTime(msg)
+	return ok
+}
+
+func dropMarker(msg *bytes.Buffer) {
+	if atomic.LoadInt64(&marked) == 0 {
+		return
+	}
+	if _, ok := markers.LoadAndDelete(msg); ok {
+		atomic.AddInt64(&marked, -1)
+	}
+}
+
+// EmitWatermark send a watermark to output, t means that no more messages
+// with an event time before t will be sent, sources call it from Worker.Process
+func EmitWatermark(ctx context.Context, output chan<- *bytes.Buffer, t time.Time) error {
+	msg := newMarker(t.UnixNano())
+	select {
+	case output <- msg:
+		return nil
+	case <-ctx.Done():
+		FreeBuffer(msg)
+		return ctx.Err()
+	}
+}
+
+// EventTime convert a field value to a time, it accepts time.Time and
+// RFC3339 strings, sources use it to derive watermarks from a configured field
+func EventTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, t)
+		return ts, err == nil
+	case []byte:
+		ts, err := time.Parse(time.RFC3339Nano, string(t))
+		return ts, err == nil
+	}
+	return time.Time{}, false
+}
+
+// watermark of a node, current is updated as soon as it advances, forwarded
+// is updated when worker reads its next message, so it is sent downstream
+// after the messages that worker produced before it
+type watermark struct {
+	current   int64
+	forwarded int64
+	notify    chan struct{}
+}
+
+func newWatermark() *watermark {
+	return &watermark{notify: make(chan struct{}, 1)}
+}
+
+// time returns current watermark, zero if there is none
+func (w *watermark) time() time.Time {
+	t := atomic.LoadInt64(&w.current)
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t)
+}
+
+func (w *watermark) forward(t int64) {
+	if t <= atomic.LoadInt64(&w.forwarded) {
+		return
+	}
+	atomic.StoreInt64(&w.forwarded, t)
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+type watermarkKey struct{}
+
+// Watermark returns input watermark of node that runs ctx, it is the minimum
+// of all upstream watermarks, zero if there is none
+func Watermark(ctx context.Context) time.Time {
+	w, _ := ctx.Value(watermarkKey{}).(*watermark)
+	if w == nil {
+		return time.Time{}
+	}
+	return w.time()
+}
+
+// IsLate returns true if a record with event time t arrived after the watermark
+func IsLate(ctx context.Context, t time.Time) bool {
+	wm := Watermark(ctx)
+	return !wm.IsZero() && t.Before(wm)
+}
+
+// inputMarks keep watermark of every Receiver edge, minimum of open edges is
+// the node watermark, it does not advance until every open edge has one,
+// when all edges are closed it is the maximum
+type inputMarks struct {
+	mtx     sync.Mutex
+	wm      *watermark
+	edges   []int64
+	closed  []bool
+	pending int64
+}
+
+func (m *inputMarks) set(i int, t int64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if t > m.edges[i] {
+		m.edges[i] = t
+		m.update()
+	}
+}
+
+func (m *inputMarks) close(i int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.closed[i] = true
+	m.update()
+}
+
+func (m *inputMarks) update() {
+	var min, max int64
+	open := false
+	for i, t := range m.edges {
+		if t > max {
+			max = t
+		}
+		if m.closed[i] {
+			continue
+		}
+		if t == 0 {
+			return
+		}
+		if !open || t < min {
+			min = t
+		}
+		open = true
+	}
+	// there is no more data when every edge is closed
+	if !open {
+		min = max
+	}
+	if min > atomic.LoadInt64(&m.wm.current) {
+		atomic.StoreInt64(&m.wm.current, min)
+		m.pending = min
+	}
+}
+
+// publish forward pending watermark, it is called after a message is delivered to worker
+func (m *inputMarks) publish() {
+	m.mtx.Lock()
+	p := m.pending
+	m.mtx.Unlock()
+	if p != 0 {
+		m.wm.forward(p)
+	}
+}
+
+// trackWatermark make r compute wm from its edges, it must be called before Receive
+func (r *Receiver) trackWatermark(wm *watermark) {
+	r.marks = &inputMarks{wm: wm, edges: make([]int64, len(r.edges)), closed: make([]bool, len(r.edges))}
+}
+
+// watermark consume msg if it is a watermark of edge i
+func (r *Receiver) watermark(i int, msg *bytes.Buffer) bool {
+	t, ok := markerTime(msg)
+	if !ok {
+		return false
+	}
+	if r.marks != nil {
+		r.marks.set(i, t)
+	}
+	FreeBuffer(msg)
+	return true
+}
+
+func (r *Receiver) inputClosed(i int) {
+	if r.marks != nil {
+		r.marks.close(i)
+	}
+}
+
+// delivered is called when worker reads a message, at this point it
+// has processed previous ones so pending watermark can be forwarded
+func (r *Receiver) delivered() {
+	if r.marks != nil {
+		r.marks.publish()
+	}
+}
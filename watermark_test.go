@@ -0,0 +1,186 @@
+package selina_test
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/licaonfee/selina"
+	"github.com/spf13/afero"
+)
+
+// event is a message with data or a watermark when mark is not zero
+type event struct {
+	data string
+	mark time.Time
+}
+
+// eventSource send events and wait until hold is closed
+type eventSource struct {
+	events []event
+	hold   chan struct{}
+}
+
+func (s *eventSource) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for _, e := range s.events {
+		if !e.mark.IsZero() {
+			if err := selina.EmitWatermark(ctx, args.Output, e.mark); err != nil {
+				return err
+			}
+			continue
+		}
+		msg := selina.GetBuffer()
+		msg.WriteString(e.data)
+		if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+			return err
+		}
+	}
+	if s.hold != nil {
+		select {
+		case <-s.hold:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// lateWriter record messages and if they were late, data is an event time
+type lateWriter struct {
+	values []string
+	late   []bool
+}
+
+func (w *lateWriter) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for msg := range args.Input {
+		t, _ := selina.EventTime(msg.String())
+		w.values = append(w.values, msg.String())
+		w.late = append(w.late, selina.IsLate(ctx, t))
+		selina.FreeBuffer(msg)
+	}
+	return nil
+}
+
+func at(sec int) time.Time {
+	return time.Unix(int64(sec), 0).UTC()
+}
+
+func stamp(sec int) string {
+	return at(sec).Format(time.RFC3339)
+}
+
+func TestWatermarkLateRecords(t *testing.T) {
+	src := selina.NewNode("src", &eventSource{events: []event{
+		{data: stamp(10)}, {mark: at(10)}, {data: stamp(5)}, {data: stamp(12)},
+	}})
+	w := &lateWriter{}
+	sink := selina.NewNode("sink", w)
+	if err := selina.LinealPipeline(src, sink).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.values) != 3 {
+		t.Fatalf("watermarks must not be delivered to workers, got %v", w.values)
+	}
+	want := []bool{false, true, false}
+	for i := range want {
+		if w.late[i] != want[i] {
+			t.Fatalf("IsLate(%s) = %v, want %v", w.values[i], w.late[i], want[i])
+		}
+	}
+	if got := sink.Stats().Watermark; !got.Equal(at(10)) {
+		t.Fatalf("Stats().Watermark = %v", got)
+	}
+}
+
+func TestWatermarkMinimum(t *testing.T) {
+	hold := make(chan struct{})
+	src1 := selina.NewNode("src1", &eventSource{events: []event{{mark: at(10)}, {data: "a"}}, hold: hold})
+	src2 := selina.NewNode("src2", &eventSource{events: []event{{mark: at(20)}, {data: "b"}}, hold: hold})
+	merge := selina.NewNode("merge", &dummyWorker{})
+	sink := selina.NewNode("sink", &sliceWriter{})
+	src1.Chain(merge)
+	src2.Chain(merge)
+	merge.Chain(sink)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- selina.FreePipeline(src1, src2, merge, sink).Run(context.Background())
+	}()
+	deadline := time.Now().Add(time.Second)
+	for merge.Stats().Watermark.IsZero() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// it can not advance until every input has a watermark
+	if got := merge.Stats().Watermark; !got.Equal(at(10)) {
+		t.Fatalf("merge Watermark = %v, want minimum %v", got, at(10))
+	}
+	close(hold)
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	// closed inputs are excluded so last watermark is forwarded at the end
+	if got := sink.Stats().Watermark; !got.Equal(at(20)) {
+		t.Fatalf("sink Watermark = %v, want %v", got, at(20))
+	}
+}
+
+func TestWatermarkInterceptors(t *testing.T) {
+	src := selina.NewNode("src", &eventSource{events: []event{{data: "a"}, {mark: at(10)}, {data: "b"}}})
+	middle := selina.NewNode("middle", &dummyWorker{})
+	sink := selina.NewNode("sink", &sliceWriter{})
+	var seen int32
+	middle.Intercept(selina.InterceptorFunc(func(ctx context.Context, info selina.MessageInfo, msg *bytes.Buffer) bool {
+		atomic.AddInt32(&seen, 1)
+		return true
+	}))
+	if err := selina.LinealPipeline(src, middle, sink).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// interceptors run twice per message, in and out
+	if got := atomic.LoadInt32(&seen); got != 4 {
+		t.Fatalf("interceptor called %d times, watermarks must be skipped", got)
+	}
+	if got := sink.Stats().Watermark; !got.Equal(at(10)) {
+		t.Fatalf("Stats().Watermark = %v", got)
+	}
+}
+
+func TestWatermarkDurableEdge(t *testing.T) {
+	src := selina.NewNode("src", &eventSource{events: []event{{data: "a"}, {mark: at(10)}, {data: "b"}}})
+	w := &sliceWriter{}
+	sink := selina.NewNode("sink", w)
+	src.ChainWith(sink, selina.EdgeOptions{Durable: &selina.DurableOptions{Fs: afero.NewMemMapFs(), Dir: "/edge"}})
+	if err := selina.FreePipeline(src, sink).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.values) != 2 || w.values[0] != "a" || w.values[1] != "b" {
+		t.Fatalf("values = %v", w.values)
+	}
+	if got := sink.Stats().Watermark; !got.Equal(at(10)) {
+		t.Fatalf("Stats().Watermark = %v", got)
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  time.Time
+		ok    bool
+	}{
+		{value: at(5), want: at(5), ok: true},
+		{value: stamp(5), want: at(5), ok: true},
+		{value: []byte(stamp(5)), want: at(5), ok: true},
+		{value: "yesterday"},
+		{value: 5},
+		{value: nil},
+	}
+	for _, tt := range tests {
+		got, ok := selina.EventTime(tt.value)
+		if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+			t.Fatalf("EventTime(%v) = %v, %v", tt.value, got, ok)
+		}
+	}
+}
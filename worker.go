@@ -14,6 +14,7 @@ import (
 // On close input channel, Process must finalize its work gracefully, and return nil
 // On context cancellation, Process finalize ASAP and return context.Cancelled
 // On finish, Process must close output channel and return error or nil
+// Every message read from input must be sent to output or passed to FreeBuffer
 type Worker interface {
 	// Process must close write only channel
 	Process(ctx context.Context, args ProcessArgs) error
@@ -5,13 +5,18 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/licaonfee/selina"
 )
 
-const waitProcessSleepDuration = time.Millisecond * 50
-const closeInputTimeout = time.Millisecond * 50
+// ProcessTimeout is the maximum time that acceptance tests wait for
+// Process to finish, it is only reached by misbehaving workers
+var ProcessTimeout = time.Second
 
 // ErrProcessIgnoreCtx worker.Process does not terminate when context is canceled
 var ErrProcessIgnoreCtx = errors.New("ignored context.Done")
@@ -22,35 +27,156 @@ var ErrNotTerminatedOnCloseInput = errors.New("not terminate on closed input")
 // ErrOutputNotClosed worker.Process does not close output channel
 var ErrOutputNotClosed = errors.New("output channel is not closed")
 
-// ATProcessCancel a worker must terminate and return context.Canceled
-// when context is canceled
-func ATProcessCancel(w selina.Worker) error {
-	input := make(chan *bytes.Buffer)
-	output := make(chan *bytes.Buffer) // unbuffered so, process wait forever
-	ctx, cancel := context.WithCancel(context.Background())
+var (
+	// ErrNilUpstreamIgnored worker.Process does not return selina.ErrNilUpstream on nil input
+	ErrNilUpstreamIgnored = errors.New("nil upstream not reported")
+	// ErrGoroutineLeak worker.Process left goroutines running after return
+	ErrGoroutineLeak = errors.New("goroutines still running after Process")
+	// ErrBufferLeak an input message was neither freed nor sent to output
+	ErrBufferLeak = errors.New("input buffer not returned to the pool")
+	// ErrOrderMismatch output messages are not the expected ones or in a different order
+	ErrOrderMismatch = errors.New("output order mismatch")
+	// ErrHandlerNotCalled ErrorHandler was not called with invalid input
+	ErrHandlerNotCalled = errors.New("error handler not called")
+	// ErrHandlerIgnored Process does not honour ErrorHandler return value
+	ErrHandlerIgnored = errors.New("error handler result ignored")
+	// ErrWorkerPanic worker.Process panics
+	ErrWorkerPanic = errors.New("worker panic")
+)
+
+// process run w.Process in a new goroutine, panics are returned as ErrWorkerPanic
+func process(ctx context.Context, w selina.Worker, args selina.ProcessArgs) <-chan error {
 	errC := make(chan error, 1)
 	go func() {
-		time.Sleep(waitProcessSleepDuration) // wait to start process
-		cancel()
-	}()
-	go func() {
-		args := selina.ProcessArgs{Input: input, Output: output}
+		defer func() {
+			if r := recover(); r != nil {
+				errC <- fmt.Errorf("%w: %v", ErrWorkerPanic, r)
+			}
+		}()
 		errC <- w.Process(ctx, args)
 	}()
-	<-ctx.Done() // wait until context is canceled
+	return errC
+}
+
+// wait for Process result until ProcessTimeout
+func wait(errC <-chan error, onTimeout error) error {
 	select {
 	case err := <-errC:
-		if err != context.Canceled {
-			if err == nil {
-				return ErrProcessIgnoreCtx
+		return err
+	case <-time.After(ProcessTimeout):
+		return onTimeout
+	}
+}
+
+// collect read output until it is closed
+func collect(output <-chan *bytes.Buffer) <-chan []*bytes.Buffer {
+	res := make(chan []*bytes.Buffer, 1)
+	go func() {
+		res <- selina.ChannelAsSlice(output)
+	}()
+	return res
+}
+
+// runWith send input messages, close input and wait for Process to finish
+func runWith(w selina.Worker, input []*bytes.Buffer) ([]*bytes.Buffer, error) {
+	in := selina.SliceAsChannelRaw(input, true)
+	output := make(chan *bytes.Buffer)
+	res := collect(output)
+	errC := process(context.Background(), w, selina.ProcessArgs{Input: in, Output: output})
+	err := wait(errC, ErrNotTerminatedOnCloseInput)
+	if errors.Is(err, ErrNotTerminatedOnCloseInput) || errors.Is(err, ErrWorkerPanic) {
+		return nil, err
+	}
+	select {
+	case out := <-res:
+		return out, err
+	case <-time.After(ProcessTimeout):
+		return nil, ErrOutputNotClosed
+	}
+}
+
+func toBuffers(data []string) []*bytes.Buffer {
+	ret := make([]*bytes.Buffer, len(data))
+	for i, d := range data {
+		ret[i] = selina.GetBuffer()
+		ret[i].WriteString(d)
+	}
+	return ret
+}
+
+// ATProcessCancel a worker must terminate, return context.Canceled and close its
+// output when context is canceled, also while it is blocked sending a message.
+// input is sent before cancel (two JSON objects when it is empty), at most
+// len(input)-1 output messages are read so a worker that emits a message per
+// input is canceled while it holds the last one
+func ATProcessCancel(w selina.Worker, input ...string) error {
+	if len(input) == 0 {
+		input = []string{"{}", "{}"}
+	}
+	in := make(chan *bytes.Buffer)
+	output := make(chan *bytes.Buffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errC := process(ctx, w, selina.ProcessArgs{Input: in, Output: output})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for _, msg := range toBuffers(input) {
+			select {
+			case in <- msg:
+			case <-ctx.Done():
+				selina.FreeBuffer(msg)
 			}
-			return err
 		}
-		return nil
-	case <-time.After(waitProcessSleepDuration):
+	}()
+	var err error
+	finished := false
+	// output is not read once limit is reached, so worker is canceled after
+	// all input is accepted with a message to send.
+	// Timeout is only reached by idle workers and sources that ignore input
+	timeout := time.After(ProcessTimeout)
+	reads := output
+	received := 0
+wait:
+	for {
+		if received == len(input)-1 {
+			reads = nil
+		}
+		select {
+		case <-sent:
+			break wait
+		case msg, ok := <-reads:
+			if !ok {
+				break wait
+			}
+			selina.FreeBuffer(msg)
+			received++
+		case err = <-errC:
+			finished = true
+			break wait
+		case <-timeout:
+			break wait
+		}
+	}
+	cancel()
+	if !finished {
+		err = wait(errC, ErrProcessIgnoreCtx)
+	}
+	switch {
+	case err == nil:
 		return ErrProcessIgnoreCtx
+	case !errors.Is(err, context.Canceled):
+		return err
+	}
+	select {
+	case out := <-collect(output):
+		for _, b := range out {
+			selina.FreeBuffer(b)
+		}
+		return nil
+	case <-time.After(ProcessTimeout):
+		return ErrOutputNotClosed
 	}
-
 }
 
 // ATProcessCloseInput a worker must finish its job and return nil
@@ -58,24 +184,14 @@ func ATProcessCancel(w selina.Worker) error {
 func ATProcessCloseInput(w selina.Worker) error {
 	input := make(chan *bytes.Buffer)
 	output := make(chan *bytes.Buffer)
-	resp := make(chan error, 1)
-	go func() {
-		args := selina.ProcessArgs{Input: input, Output: output}
-		resp <- w.Process(context.Background(), args)
-	}()
 	go func() {
 		for range output {
 			// Consume output to avoid Process lock
 		}
 	}()
-	time.Sleep(waitProcessSleepDuration)
+	errC := process(context.Background(), w, selina.ProcessArgs{Input: input, Output: output})
 	close(input)
-	select {
-	case err := <-resp:
-		return err
-	case <-time.After(closeInputTimeout):
-		return ErrNotTerminatedOnCloseInput
-	}
+	return wait(errC, ErrNotTerminatedOnCloseInput)
 }
 
 // ATProcessCloseOutput a worker must close its output channel on exit
@@ -104,3 +220,157 @@ func ATProcessCloseOutput(w selina.Worker) error {
 	}
 	return nil
 }
+
+// ATProcessNilUpstream a worker that requires an upstream must return
+// selina.ErrNilUpstream and close its output when input is nil
+func ATProcessNilUpstream(w selina.Worker) error {
+	output := make(chan *bytes.Buffer)
+	res := collect(output)
+	errC := process(context.Background(), w, selina.ProcessArgs{Input: nil, Output: output})
+	err := wait(errC, ErrNilUpstreamIgnored)
+	if !errors.Is(err, selina.ErrNilUpstream) {
+		if err == nil || errors.Is(err, ErrNilUpstreamIgnored) {
+			return ErrNilUpstreamIgnored
+		}
+		return err
+	}
+	select {
+	case out := <-res:
+		for _, b := range out {
+			selina.FreeBuffer(b)
+		}
+		return nil
+	case <-time.After(ProcessTimeout):
+		return ErrOutputNotClosed
+	}
+}
+
+// ATProcessNoLeak all goroutines started by a worker must finish before Process returns
+// this test count running goroutines so it must not run in parallel with other tests
+func ATProcessNoLeak(w selina.Worker, input []string) error {
+	before := runtime.NumGoroutine()
+	out, err := runWith(w, toBuffers(input))
+	if errors.Is(err, ErrNotTerminatedOnCloseInput) || errors.Is(err, ErrOutputNotClosed) {
+		return err
+	}
+	for _, b := range out {
+		selina.FreeBuffer(b)
+	}
+	deadline := time.Now().Add(ProcessTimeout)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %d before, %d after", ErrGoroutineLeak, before, runtime.NumGoroutine())
+		}
+		runtime.Gosched()
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// ATProcessFreeBuffers every input message must be returned to the pool
+// with selina.FreeBuffer or sent downstream
+func ATProcessFreeBuffers(w selina.Worker, input []string) error {
+	in := toBuffers(input)
+	freed := make([]int32, len(in))
+	for i, b := range in {
+		i := i
+		selina.OnFree(b, func() { atomic.StoreInt32(&freed[i], 1) })
+	}
+	out, err := runWith(w, in)
+	if errors.Is(err, ErrNotTerminatedOnCloseInput) || errors.Is(err, ErrOutputNotClosed) {
+		return err
+	}
+	sent := make(map[*bytes.Buffer]struct{}, len(out))
+	for _, b := range out {
+		sent[b] = struct{}{}
+	}
+	defer func() {
+		for _, b := range out {
+			selina.FreeBuffer(b)
+		}
+	}()
+	for i, b := range in {
+		if _, ok := sent[b]; ok {
+			continue
+		}
+		if atomic.LoadInt32(&freed[i]) == 0 {
+			return fmt.Errorf("%w: message %d (%q)", ErrBufferLeak, i, input[i])
+		}
+	}
+	return nil
+}
+
+// ATProcessOrder output messages must be exactly want in the same order
+func ATProcessOrder(w selina.Worker, input []string, want []string) error {
+	out, err := runWith(w, toBuffers(input))
+	if err != nil {
+		return err
+	}
+	got := make([]string, len(out))
+	for i, b := range out {
+		got[i] = b.String()
+		selina.FreeBuffer(b)
+	}
+	if len(got) == 0 && len(want) == 0 {
+		return nil
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%w: got %q, want %q", ErrOrderMismatch, got, want)
+	}
+	return nil
+}
+
+// ATProcessErrorHandler a worker must call its ErrorHandler on invalid input,
+// continue when handler returns true and fail when it returns false
+// newWorker must return a worker configured with given handler
+// and input must contain at least one invalid message
+func ATProcessErrorHandler(newWorker func(selina.ErrorHandler) selina.Worker, input []string) error {
+	// handlers may be called from other goroutines
+	var calls int32
+	skip := func(error) bool {
+		atomic.AddInt32(&calls, 1)
+		return true
+	}
+	out, err := runWith(newWorker(skip), toBuffers(input))
+	for _, b := range out {
+		selina.FreeBuffer(b)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: handled error returned %v", ErrHandlerIgnored, err)
+	}
+	if atomic.LoadInt32(&calls) == 0 {
+		return ErrHandlerNotCalled
+	}
+	fail := func(error) bool { return false }
+	out, err = runWith(newWorker(fail), toBuffers(input))
+	for _, b := range out {
+		selina.FreeBuffer(b)
+	}
+	if errors.Is(err, ErrNotTerminatedOnCloseInput) || errors.Is(err, ErrOutputNotClosed) {
+		return err
+	}
+	if err == nil {
+		return fmt.Errorf("%w: unhandled error not returned", ErrHandlerIgnored)
+	}
+	return nil
+}
+
+// ATProcessFuzz feed a worker with arbitrary messages, data is split in messages by
+// new lines. Process may return an error but it must not panic or hang
+// and must close its output, use it inside a testing.F fuzz target
+func ATProcessFuzz(w selina.Worker, data []byte) error {
+	msgs := bytes.Split(data, []byte("\n"))
+	in := make([]*bytes.Buffer, len(msgs))
+	for i, m := range msgs {
+		in[i] = selina.GetBuffer()
+		in[i].Write(m)
+	}
+	out, err := runWith(w, in)
+	for _, b := range out {
+		selina.FreeBuffer(b)
+	}
+	if errors.Is(err, ErrWorkerPanic) || errors.Is(err, ErrNotTerminatedOnCloseInput) || errors.Is(err, ErrOutputNotClosed) {
+		return err
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package workers_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/licaonfee/selina/workers"
@@ -116,6 +118,30 @@ func (b *badCtxPropagation) Process(ctx context.Context, args selina.ProcessArgs
 	}
 }
 
+var _ selina.Worker = (*blockingSendWorker)(nil)
+
+// blockingSendWorker ignore context while it sends a message
+type blockingSendWorker struct{}
+
+func (b *blockingSendWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			args.Output <- msg
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func cancelAT(w selina.Worker) error {
+	return workers.ATProcessCancel(w, "1", "2")
+}
+
 // Test if all Acceptance tests pass and fail in correct cases
 func TestAcceptanceTests(t *testing.T) {
 	tests := []struct {
@@ -126,10 +152,22 @@ func TestAcceptanceTests(t *testing.T) {
 	}{
 		{
 			name:    "Cancel Process OK",
-			at:      workers.ATProcessCancel,
+			at:      cancelAT,
 			w:       &idealWorker{},
 			wantErr: nil,
 		},
+		{
+			name:    "Cancel blocked on send",
+			at:      cancelAT,
+			w:       &blockingSendWorker{},
+			wantErr: workers.ErrProcessIgnoreCtx,
+		},
+		{
+			name:    "Cancel echo",
+			at:      cancelAT,
+			w:       &echoWorker{},
+			wantErr: nil,
+		},
 		{
 			name:    "Close input OK",
 			at:      workers.ATProcessCloseInput,
@@ -144,13 +182,13 @@ func TestAcceptanceTests(t *testing.T) {
 		},
 		{
 			name:    "Cancel ignore context",
-			at:      workers.ATProcessCancel,
+			at:      cancelAT,
 			w:       &badContextWorker{},
 			wantErr: workers.ErrProcessIgnoreCtx,
 		},
 		{
 			name:    "Cancel bad propagation",
-			at:      workers.ATProcessCancel,
+			at:      cancelAT,
 			w:       &badCtxPropagation{},
 			wantErr: errBadCtxError,
 		},
@@ -187,3 +225,235 @@ func TestAcceptanceTests(t *testing.T) {
 		})
 	}
 }
+
+var _ selina.Worker = (*echoWorker)(nil)
+
+// echoWorker send every message downstream
+type echoWorker struct{}
+
+func (e *echoWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	if args.Input == nil {
+		return selina.ErrNilUpstream
+	}
+	for {
+		select {
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+var _ selina.Worker = (*reverseWorker)(nil)
+
+// reverseWorker buffer all messages and send them in reverse order
+type reverseWorker struct{}
+
+func (r *reverseWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	all := selina.ChannelAsSlice(args.Input)
+	for i := len(all) - 1; i >= 0; i-- {
+		if err := selina.SendContext(ctx, all[i], args.Output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ selina.Worker = (*dropWorker)(nil)
+
+// dropWorker discard messages without return them to the pool
+type dropWorker struct{}
+
+func (d *dropWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for range args.Input {
+	}
+	return nil
+}
+
+var _ selina.Worker = (*drainWorker)(nil)
+
+// drainWorker read every message and never free it
+type drainWorker struct{}
+
+func (d *drainWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for msg := range args.Input {
+		_, _ = io.Copy(io.Discard, msg)
+	}
+	return nil
+}
+
+var _ selina.Worker = (*leakWorker)(nil)
+
+// leakWorker start a goroutine that never ends
+type leakWorker struct {
+	block chan struct{}
+}
+
+func (l *leakWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	go func() {
+		<-l.block
+	}()
+	for range args.Input {
+	}
+	return nil
+}
+
+var _ selina.Worker = (*panicWorker)(nil)
+
+type panicWorker struct{}
+
+func (p *panicWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	for msg := range args.Input {
+		if msg.String() == "boom" {
+			panic("boom")
+		}
+	}
+	close(args.Output)
+	return nil
+}
+
+var errInvalidMessage = errors.New("invalid message")
+
+// handlerWorker fail with messages that starts with "bad"
+type handlerWorker struct {
+	handler selina.ErrorHandler
+	ignore  bool
+}
+
+func (h *handlerWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for msg := range args.Input {
+		if bytes.HasPrefix(msg.Bytes(), []byte("bad")) {
+			selina.FreeBuffer(msg)
+			if h.ignore {
+				continue
+			}
+			if h.handler(errInvalidMessage) {
+				continue
+			}
+			return errInvalidMessage
+		}
+		if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestATProcessNilUpstream(t *testing.T) {
+	if err := workers.ATProcessNilUpstream(&echoWorker{}); err != nil {
+		t.Fatalf("ATProcessNilUpstream() err = %v", err)
+	}
+	if err := workers.ATProcessNilUpstream(&reverseWorker{}); !errors.Is(err, workers.ErrNilUpstreamIgnored) {
+		t.Fatalf("ATProcessNilUpstream() err = %v", err)
+	}
+}
+
+func TestATProcessNoLeak(t *testing.T) {
+	if err := workers.ATProcessNoLeak(&echoWorker{}, []string{"a", "b"}); err != nil {
+		t.Fatalf("ATProcessNoLeak() err = %v", err)
+	}
+	lw := &leakWorker{block: make(chan struct{})}
+	defer close(lw.block)
+	if err := workers.ATProcessNoLeak(lw, []string{"a"}); !errors.Is(err, workers.ErrGoroutineLeak) {
+		t.Fatalf("ATProcessNoLeak() err = %v", err)
+	}
+}
+
+func TestATProcessFreeBuffers(t *testing.T) {
+	input := []string{"a", "b", "bad"}
+	if err := workers.ATProcessFreeBuffers(&echoWorker{}, input); err != nil {
+		t.Fatalf("ATProcessFreeBuffers() err = %v", err)
+	}
+	if err := workers.ATProcessFreeBuffers(&handlerWorker{ignore: true}, input); err != nil {
+		t.Fatalf("ATProcessFreeBuffers() err = %v", err)
+	}
+	if err := workers.ATProcessFreeBuffers(&dropWorker{}, input); !errors.Is(err, workers.ErrBufferLeak) {
+		t.Fatalf("ATProcessFreeBuffers() err = %v", err)
+	}
+	if err := workers.ATProcessFreeBuffers(&drainWorker{}, input); !errors.Is(err, workers.ErrBufferLeak) {
+		t.Fatalf("ATProcessFreeBuffers() drained buffers are not freed, err = %v", err)
+	}
+	// big buffers are freed but not reset
+	defer func(size int) { selina.MaxPoolBufferSize = size }(selina.MaxPoolBufferSize)
+	selina.MaxPoolBufferSize = 0
+	if err := workers.ATProcessFreeBuffers(&handlerWorker{ignore: true}, []string{"bad", "bad"}); err != nil {
+		t.Fatalf("ATProcessFreeBuffers() err = %v", err)
+	}
+}
+
+func TestATProcessOrder(t *testing.T) {
+	input := []string{"1", "2", "3"}
+	if err := workers.ATProcessOrder(&echoWorker{}, input, input); err != nil {
+		t.Fatalf("ATProcessOrder() err = %v", err)
+	}
+	if err := workers.ATProcessOrder(&reverseWorker{}, input, input); !errors.Is(err, workers.ErrOrderMismatch) {
+		t.Fatalf("ATProcessOrder() err = %v", err)
+	}
+}
+
+func TestATProcessErrorHandler(t *testing.T) {
+	input := []string{"good", "bad", "good"}
+	honour := func(h selina.ErrorHandler) selina.Worker { return &handlerWorker{handler: h} }
+	if err := workers.ATProcessErrorHandler(honour, input); err != nil {
+		t.Fatalf("ATProcessErrorHandler() err = %v", err)
+	}
+	ignore := func(h selina.ErrorHandler) selina.Worker { return &handlerWorker{handler: h, ignore: true} }
+	if err := workers.ATProcessErrorHandler(ignore, input); !errors.Is(err, workers.ErrHandlerNotCalled) {
+		t.Fatalf("ATProcessErrorHandler() err = %v", err)
+	}
+}
+
+// asyncHandlerWorker call its handler from another goroutine
+type asyncHandlerWorker struct {
+	handler selina.ErrorHandler
+}
+
+func (a *asyncHandlerWorker) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	for msg := range args.Input {
+		selina.FreeBuffer(msg)
+		res := make(chan bool)
+		go func() { res <- a.handler(errInvalidMessage) }()
+		if !<-res {
+			return errInvalidMessage
+		}
+	}
+	return nil
+}
+
+func TestATProcessErrorHandlerAsync(t *testing.T) {
+	async := func(h selina.ErrorHandler) selina.Worker { return &asyncHandlerWorker{handler: h} }
+	if err := workers.ATProcessErrorHandler(async, []string{"bad"}); err != nil {
+		t.Fatalf("ATProcessErrorHandler() err = %v", err)
+	}
+}
+
+func TestATProcessFuzz(t *testing.T) {
+	if err := workers.ATProcessFuzz(&echoWorker{}, []byte("a\nboom\nc")); err != nil {
+		t.Fatalf("ATProcessFuzz() err = %v", err)
+	}
+	if err := workers.ATProcessFuzz(&panicWorker{}, []byte("a\nboom\nc")); !errors.Is(err, workers.ErrWorkerPanic) {
+		t.Fatalf("ATProcessFuzz() err = %v", err)
+	}
+}
+
+func FuzzEchoWorker(f *testing.F) {
+	f.Add([]byte("line1\nline2"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := workers.ATProcessFuzz(&echoWorker{}, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Package compress transparent compression for file readers and writers
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format identify a compression algorithm
+type Format string
+
+// Available formats, zero value is the same as None
+const (
+	None  Format = "none"
+	Auto  Format = "auto"
+	Gzip  Format = "gzip"
+	Zlib  Format = "zlib"
+	Bzip2 Format = "bzip2"
+)
+
+var (
+	// ErrUnknownFormat is returned when a Format is not supported
+	ErrUnknownFormat = errors.New("unknown compression format")
+	// ErrWriteUnsupported is returned when a Format can only be decompressed
+	ErrWriteUnsupported = errors.New("compression format is read only")
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// Check if f is a known Format
+func (f Format) Check() error {
+	switch f {
+	case "", None, Auto, Gzip, Zlib, Bzip2:
+		return nil
+	default:
+		return fmt.Errorf("%w '%s'", ErrUnknownFormat, f)
+	}
+}
+
+// CheckWrite if f is a Format supported by NewWriter
+func (f Format) CheckWrite() error {
+	switch f {
+	case "", None, Gzip, Zlib:
+		return nil
+	case Bzip2:
+		return fmt.Errorf("%w '%s'", ErrWriteUnsupported, f)
+	default:
+		return fmt.Errorf("%w '%s'", ErrUnknownFormat, f)
+	}
+}
+
+// FromFilename guess a Format from file extension, None is returned
+// for unknown extensions
+func FromFilename(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".gzip":
+		return Gzip
+	case ".zz", ".zlib":
+		return Zlib
+	case ".bz2", ".bzip2":
+		return Bzip2
+	default:
+		return None
+	}
+}
+
+// Detect peek the first bytes of r to identify its Format
+// None is returned when data does not look compressed
+func Detect(r *bufio.Reader) Format {
+	head, _ := r.Peek(3)
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(head, bzip2Magic):
+		return Bzip2
+	case isZlibHeader(head):
+		return Zlib
+	default:
+		return None
+	}
+}
+
+// only default deflate headers are detected because others
+// are valid ascii text like "x^"
+func isZlibHeader(head []byte) bool {
+	if len(head) < 2 || head[0] != 0x78 {
+		return false
+	}
+	switch head[1] {
+	case 0x01, 0x9c, 0xda:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewReader wraps r with a decompressor, Auto detect format from magic bytes
+// closing returned reader does not close r
+func NewReader(r io.Reader, f Format) (io.ReadCloser, error) {
+	if f == Auto {
+		br := bufio.NewReader(r)
+		f = Detect(br)
+		r = br
+	}
+	switch f {
+	case "", None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zlib:
+		return zlib.NewReader(r)
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownFormat, f)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// NewWriter wraps w with a compressor, Close must be called to flush
+// all data, closing returned writer does not close w
+func NewWriter(w io.Writer, f Format) (io.WriteCloser, error) {
+	if err := f.CheckWrite(); err != nil {
+		return nil, err
+	}
+	switch f {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zlib:
+		return zlib.NewWriter(w), nil
+	default:
+		return nopWriteCloser{Writer: w}, nil
+	}
+}
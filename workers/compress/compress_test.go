@@ -0,0 +1,110 @@
+package compress_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/licaonfee/selina/workers/compress"
+)
+
+func compressed(t *testing.T, f compress.Format, data string) []byte {
+	b := &bytes.Buffer{}
+	w, err := compress.NewWriter(b, f)
+	if err != nil {
+		t.Fatalf("NewWriter() err = %v", err)
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatalf("Write() err = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() err = %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	const data = "name,id\nselina,1\n"
+	for _, f := range []compress.Format{compress.None, compress.Gzip, compress.Zlib} {
+		t.Run(string(f), func(t *testing.T) {
+			for _, readAs := range []compress.Format{f, compress.Auto} {
+				r, err := compress.NewReader(bytes.NewReader(compressed(t, f, data)), readAs)
+				if err != nil {
+					t.Fatalf("NewReader() err = %v", err)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("ReadAll() err = %v", err)
+				}
+				if string(got) != data {
+					t.Fatalf("ReadAll() got = %q, want = %q", got, data)
+				}
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	_ = w.Close()
+	tests := []struct {
+		name string
+		data []byte
+		want compress.Format
+	}{
+		{name: "gzip", data: gz.Bytes(), want: compress.Gzip},
+		{name: "bzip2", data: []byte("BZh91AY&SY"), want: compress.Bzip2},
+		{name: "zlib", data: []byte{0x78, 0x9c, 0x00}, want: compress.Zlib},
+		{name: "zlib like text", data: []byte("x^2"), want: compress.None},
+		{name: "text", data: []byte("plain text"), want: compress.None},
+		{name: "empty", data: []byte{}, want: compress.None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compress.Detect(bufio.NewReader(bytes.NewReader(tt.data)))
+			if got != tt.want {
+				t.Fatalf("Detect() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	tests := map[string]compress.Format{
+		"/data/file.csv.gz": compress.Gzip,
+		"file.GZ":           compress.Gzip,
+		"file.zz":           compress.Zlib,
+		"file.bz2":          compress.Bzip2,
+		"file.csv":          compress.None,
+	}
+	for name, want := range tests {
+		if got := compress.FromFilename(name); got != want {
+			t.Errorf("FromFilename(%s) = %v, want = %v", name, got, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := compress.NewWriter(io.Discard, compress.Bzip2); !errors.Is(err, compress.ErrWriteUnsupported) {
+		t.Errorf("NewWriter() err = %v", err)
+	}
+	if _, err := compress.NewWriter(io.Discard, "lz4"); !errors.Is(err, compress.ErrUnknownFormat) {
+		t.Errorf("NewWriter() err = %v", err)
+	}
+	if _, err := compress.NewReader(bytes.NewReader(nil), "lz4"); !errors.Is(err, compress.ErrUnknownFormat) {
+		t.Errorf("NewReader() err = %v", err)
+	}
+	if err := compress.Format("lz4").Check(); !errors.Is(err, compress.ErrUnknownFormat) {
+		t.Errorf("Check() err = %v", err)
+	}
+	if err := compress.Bzip2.CheckWrite(); !errors.Is(err, compress.ErrWriteUnsupported) {
+		t.Errorf("CheckWrite() err = %v", err)
+	}
+	if err := compress.Auto.CheckWrite(); !errors.Is(err, compress.ErrUnknownFormat) {
+		t.Errorf("CheckWrite() err = %v", err)
+	}
+}
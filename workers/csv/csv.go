@@ -5,19 +5,37 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
-	"strconv"
 
 	"github.com/licaonfee/selina"
 )
 
 var _ selina.Worker = (*Encoder)(nil)
 
+// Unknown configure how fields that are not in header are encoded
+type Unknown string
+
+const (
+	// UnknownIgnore fields not in header are skipped
+	UnknownIgnore Unknown = "ignore"
+	// UnknownStrict a record with a field not in header is an error
+	UnknownStrict Unknown = "strict"
+	// UnknownSpill fields not in header are written as a json object in an extra column
+	UnknownSpill Unknown = "spill"
+)
+
+// DefaultSpillColumn is the name of the extra column of UnknownSpill
+const DefaultSpillColumn = "_extra"
+
+// ErrUnknownField is returned for fields not in header when Unknown is UnknownStrict
+var ErrUnknownField = errors.New("unknown field")
+
 // EncoderOptions configure csv encoding
 type EncoderOptions struct {
-	// Header acts as a filter, if a field is not in header is skipped
+	// Header acts as a filter, fields not in header are handled by Unknown
 	Header []string
 	// Comma default ,
 	Comma rune
@@ -25,6 +43,37 @@ type EncoderOptions struct {
 	UseCRLF    bool
 	Handler    selina.ErrorHandler
 	ReadFormat selina.Unmarshaler
+	// Null text of null values, default is an empty cell like missing fields
+	Null string
+	// True text of true booleans, default true
+	True string
+	// False text of false booleans, default false
+	False string
+	// FloatPrecision decimals of float values, default is the shortest
+	// representation that keeps its value
+	FloatPrecision int
+	// TimeLayout format of time values, default time.RFC3339Nano
+	TimeLayout string
+	// Unknown fields mode, default is UnknownIgnore
+	Unknown Unknown
+	// SpillColumn name of the extra column of UnknownSpill, default DefaultSpillColumn
+	SpillColumn string
+	// NoHeader do not write header, to write it at the top of every file
+	// when output is rotated use filesystem.WriterOptions.Header
+	NoHeader bool
+}
+
+// Check if a combination of options is valid
+func (o EncoderOptions) Check() error {
+	switch o.Unknown {
+	case "", UnknownIgnore, UnknownStrict, UnknownSpill:
+	default:
+		return fmt.Errorf("invalid unknown fields mode '%s'", o.Unknown)
+	}
+	if o.SpillColumn != "" && o.Unknown != UnknownSpill {
+		return errors.New("spill column requires spill mode")
+	}
+	return nil
 }
 
 // Encoder transform messages into csv text
@@ -38,22 +87,25 @@ func (e *Encoder) Process(ctx context.Context, args selina.ProcessArgs) error {
 	if args.Input == nil {
 		return selina.ErrNilUpstream
 	}
+	if err := e.opts.Check(); err != nil {
+		return err
+	}
 	buff := &bytes.Buffer{}
 	w := csv.NewWriter(buff)
 	if e.opts.Comma != rune(0) {
 		w.Comma = e.opts.Comma
 	}
 	w.UseCRLF = e.opts.UseCRLF
-	errHandler := selina.DefaultErrorHandler
-	if e.opts.Handler != nil {
-		errHandler = e.opts.Handler
-	}
 
-	var headerWriten bool
+	headerWriten := e.opts.NoHeader
+	f := newFormatter(e.opts)
 	rf := selina.DefaultUnmarshaler
 	if e.opts.ReadFormat != nil {
 		rf = e.opts.ReadFormat
 	}
+	// fields are columns taken from records, header also has spill column
+	fields := e.opts.Header
+	var header []string
 	for {
 		select {
 		case <-ctx.Done():
@@ -62,65 +114,130 @@ func (e *Encoder) Process(ctx context.Context, args selina.ProcessArgs) error {
 			if !ok {
 				return nil
 			}
-			data := make(map[string]interface{})
-			err := rf(msg.Bytes(), &data)
-			selina.FreeBuffer(msg)
-			switch {
-			case err == nil:
-			case errHandler(err):
-				continue
-			default:
+			var row []string
+			err := selina.HandleMessage(ctx, msg.Bytes(), e.opts.Handler, func() error {
+				data := make(map[string]interface{})
+				if err := rf(msg.Bytes(), &data); err != nil {
+					return err
+				}
+				if len(fields) == 0 {
+					fields = Fields(data)
+				}
+				var err error
+				row, err = e.row(f, fields, data)
 				return err
+			})
+			if err != nil || row == nil {
+				selina.FreeBuffer(msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if header == nil {
+				header = e.header(fields)
 			}
 			if !headerWriten {
-				if len(e.opts.Header) == 0 {
-					e.opts.Header = getHeader(data)
-				}
-				if err := sendData(ctx, e.opts.Header, w, buff, args.Output); err != nil {
+				if err := sendData(ctx, nil, header, w, buff, args.Output); err != nil {
+					selina.FreeBuffer(msg)
 					return err
 				}
 				headerWriten = true
 			}
-			res := getRow(e.opts.Header, data)
-			if err := sendData(ctx, res, w, buff, args.Output); err != nil {
+			err = sendData(ctx, msg, row, w, buff, args.Output)
+			selina.FreeBuffer(msg)
+			if err != nil {
 				return err
 			}
 		}
 	}
 }
 
+// header returns encoded columns, including spill column
+func (e *Encoder) header(fields []string) []string {
+	if e.opts.Unknown != UnknownSpill {
+		return fields
+	}
+	return append(append([]string{}, fields...), e.spillColumn())
+}
+
+func (e *Encoder) spillColumn() string {
+	if e.opts.SpillColumn != "" {
+		return e.opts.SpillColumn
+	}
+	return DefaultSpillColumn
+}
+
+// row returns cells of data and handle fields not in header
+func (e *Encoder) row(f Formatter, fields []string, data map[string]interface{}) ([]string, error) {
+	row, err := getRow(f, fields, data)
+	if err != nil || e.opts.Unknown == "" || e.opts.Unknown == UnknownIgnore {
+		return row, err
+	}
+	known := make(map[string]struct{}, len(fields))
+	for _, name := range fields {
+		known[name] = struct{}{}
+	}
+	var unknown map[string]interface{}
+	for name, value := range data {
+		if _, ok := known[name]; ok {
+			continue
+		}
+		if e.opts.Unknown == UnknownStrict {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, name)
+		}
+		if unknown == nil {
+			unknown = make(map[string]interface{})
+		}
+		unknown[name] = value
+	}
+	switch {
+	case e.opts.Unknown == UnknownStrict:
+		return row, nil
+	case unknown == nil:
+		return append(row, ""), nil
+	}
+	cell, err := f.Format(unknown)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", e.spillColumn(), err)
+	}
+	return append(row, cell), nil
+}
+
 // NewEncoder returns a new Encoder with given options
 func NewEncoder(opts EncoderOptions) *Encoder {
 	return &Encoder{opts: opts}
 }
 
-func getHeader(sample map[string]interface{}) []string {
-	header := make([]string, 0, len(sample))
-	for k := range sample {
+// Fields returns sorted field names of record, it is the header
+// taken from first record when an encoder has no header
+func Fields(record map[string]interface{}) []string {
+	header := make([]string, 0, len(record))
+	for k := range record {
 		header = append(header, k)
 	}
 	sort.Strings(header)
 	return header
 }
 
-func getRow(header []string, data map[string]interface{}) []string {
+func getRow(f Formatter, header []string, data map[string]interface{}) ([]string, error) {
 	res := make([]string, len(header))
 	for i := 0; i < len(header); i++ {
 		value, ok := data[header[i]]
 		if !ok {
 			continue
 		}
-		switch v := value.(type) {
-		case float64:
-			res[i] = strconv.FormatFloat(v, 'f', -1, 64)
-		case string:
-			res[i] = v
+		cell, err := f.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", header[i], err)
 		}
+		res[i] = cell
 	}
-	return res
+	return res, nil
 }
 
-func sendData(ctx context.Context, row []string, w *csv.Writer, buff *bytes.Buffer, output chan<- *bytes.Buffer) error {
+// sendData write row as a new message derived from msg (see selina.Forward), msg can be nil
+func sendData(ctx context.Context, msg *bytes.Buffer, row []string, w *csv.Writer, buff *bytes.Buffer, output chan<- *bytes.Buffer) error {
 	buff.Reset()
 	if err := w.Write(row); err != nil {
 		return err
@@ -128,6 +245,9 @@ func sendData(ctx context.Context, row []string, w *csv.Writer, buff *bytes.Buff
 	w.Flush()
 	b := selina.GetBuffer()
 	_, _ = io.Copy(b, buff)
+	if msg != nil {
+		selina.Forward(msg, b)
+	}
 	if err := selina.SendContext(ctx, b, output); err != nil {
 		return err
 	}
@@ -136,6 +256,22 @@ func sendData(ctx context.Context, row []string, w *csv.Writer, buff *bytes.Buff
 
 var _ selina.Worker = (*Decoder)(nil)
 
+// Ragged configure how rows with a different number of columns than header are decoded
+type Ragged string
+
+const (
+	// RaggedTruncate extra columns are dropped and missing columns are omitted
+	RaggedTruncate Ragged = "truncate"
+	// RaggedPad missing columns are null and extra columns are named column_N, N starts at 1
+	RaggedPad Ragged = "pad"
+	// RaggedError a row with a different number of columns is an error
+	RaggedError Ragged = "error"
+)
+
+// ErrRaggedRow is returned for rows with a different number of columns than header when Ragged
+// is RaggedError, and for rows with more columns than header when Ragged is empty
+var ErrRaggedRow = errors.New("wrong number of columns")
+
 // DecoderOptions configure csv read format
 type DecoderOptions struct {
 	Header  []string
@@ -143,6 +279,42 @@ type DecoderOptions struct {
 	Comment rune
 	Handler selina.ErrorHandler
 	Codec   selina.Marshaler
+	// HeaderFromFirst take header from first record, when Header is
+	// not empty first record is skipped and Header is used instead
+	HeaderFromFirst bool
+	// Ragged default every row must have as many columns as the first one
+	// (see csv.ErrFieldCount) and missing columns are omitted
+	Ragged Ragged
+	// InferTypes decode numbers and booleans instead of strings
+	InferTypes bool
+	// Types of columns, they take precedence over InferTypes,
+	// empty cells of a column with a type other than TypeString are null
+	Types map[string]ColumnType
+	// MultiLine when a message ends inside a quoted field next messages are
+	// joined with a newline until the field is closed, so records split by
+	// lines (i.e. by text.Reader) are decoded as RFC 4180 multi-line fields
+	MultiLine bool
+	// LazyQuotes allow quotes in unquoted fields and non doubled quotes in
+	// quoted fields (see csv.Reader.LazyQuotes)
+	LazyQuotes bool
+}
+
+// ErrOpenQuote is returned when input is closed inside a quoted field with MultiLine
+var ErrOpenQuote = errors.New("quoted field is not closed")
+
+// Check if a combination of options is valid
+func (o DecoderOptions) Check() error {
+	switch o.Ragged {
+	case "", RaggedTruncate, RaggedPad, RaggedError:
+	default:
+		return fmt.Errorf("invalid ragged mode '%s'", o.Ragged)
+	}
+	for col, t := range o.Types {
+		if err := t.Check(); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return nil
 }
 
 // Decoder parse csv lines into key value pairs
@@ -150,56 +322,162 @@ type Decoder struct {
 	opts DecoderOptions
 }
 
+// record convert row into key value pairs using header
+func (d *Decoder) record(header, row []string) (map[string]interface{}, error) {
+	if len(row) != len(header) && d.opts.Ragged == RaggedError {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrRaggedRow, len(row), len(header))
+	}
+	res := make(map[string]interface{}, len(header))
+	for i, cell := range row {
+		var name string
+		switch {
+		case i < len(header):
+			name = header[i]
+		case d.opts.Ragged == RaggedPad:
+			name = fmt.Sprintf("column_%d", i+1)
+		case d.opts.Ragged == RaggedTruncate:
+			continue
+		default:
+			return nil, fmt.Errorf("%w: got %d, header has %d", ErrRaggedRow, len(row), len(header))
+		}
+		value, err := d.value(name, cell)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		res[name] = value
+	}
+	if d.opts.Ragged == RaggedPad {
+		for i := len(row); i < len(header); i++ {
+			res[header[i]] = nil
+		}
+	}
+	return res, nil
+}
+
+func (d *Decoder) value(name, cell string) (interface{}, error) {
+	if t, ok := d.opts.Types[name]; ok {
+		return t.Parse(cell)
+	}
+	if d.opts.InferTypes {
+		return infer(cell), nil
+	}
+	return cell, nil
+}
+
 // Process implements selina.Worker interface
 func (d *Decoder) Process(ctx context.Context, args selina.ProcessArgs) error {
 	defer close(args.Output)
 	if args.Input == nil {
 		return selina.ErrNilUpstream
 	}
+	if err := d.opts.Check(); err != nil {
+		return err
+	}
+	header := d.opts.Header
+	needHeader := d.opts.HeaderFromFirst
 	buff := &bytes.Buffer{}
 	r := csv.NewReader(buff)
 	if d.opts.Comma != rune(0) {
 		r.Comma = d.opts.Comma
 	}
 	r.Comment = d.opts.Comment
+	r.LazyQuotes = d.opts.LazyQuotes
 	r.ReuseRecord = true
-	errHandler := selina.DefaultErrorHandler
-	if d.opts.Handler != nil {
-		errHandler = d.opts.Handler
+	if d.opts.Ragged != "" {
+		r.FieldsPerRecord = -1
 	}
 	codec := selina.DefaultMarshaler
 	if d.opts.Codec != nil {
 		codec = d.opts.Codec
 	}
+	// parts are messages of a record with an open quoted field, joined in pending
+	var parts []*bytes.Buffer
+	pending := &bytes.Buffer{}
+	defer func() {
+		for _, p := range parts {
+			selina.FreeBuffer(p)
+		}
+	}()
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case msg, ok := <-args.Input:
 			if !ok {
-				return nil
+				if len(parts) == 0 {
+					return nil
+				}
+				return selina.HandleMessage(ctx, pending.Bytes(), d.opts.Handler, func() error {
+					return ErrOpenQuote
+				})
 			}
-			buff.Reset()
-			_, _ = io.Copy(buff, msg)
-			selina.FreeBuffer(msg)
-			row, err := r.Read()
-			switch {
-			case err == nil:
-			case err == io.EOF || errHandler(err):
+			data := msg.Bytes()
+			if len(parts) > 0 {
+				pending.WriteByte('\n')
+				pending.Write(data)
+				data = pending.Bytes()
+			}
+			if d.opts.MultiLine && openQuote(data, r.Comma, r.LazyQuotes) {
+				if len(parts) == 0 {
+					pending.Write(data)
+				}
+				parts = append(parts, msg)
 				continue
-			default:
-				return err
 			}
-			res := make(map[string]interface{})
-			for i := 0; i < len(row); i++ {
-				res[d.opts.Header[i]] = row[i]
+			var nb *bytes.Buffer
+			var empty bool
+			err := selina.HandleMessage(ctx, data, d.opts.Handler, func() error {
+				buff.Reset()
+				buff.Write(data)
+				row, err := r.Read()
+				switch {
+				case err == io.EOF:
+					empty = true
+					return nil
+				case err != nil:
+					return err
+				}
+				if needHeader {
+					needHeader = false
+					empty = true
+					if len(header) == 0 {
+						header = append([]string(nil), row...)
+					}
+					return nil
+				}
+				res, err := d.record(header, row)
+				if err != nil {
+					return err
+				}
+				b, err := codec(res)
+				if err != nil {
+					return fmt.Errorf("encoding %w", err)
+				}
+				nb = selina.GetBuffer()
+				nb.Write(b)
+				return nil
+			})
+			parts = append(parts, msg)
+			switch {
+			case nb != nil:
+				forwardAll(parts, nb)
+			case empty:
+				// blank lines, comments and header are processed without output
+				for _, p := range parts {
+					selina.Ack(p, nil)
+				}
+			}
+			for _, p := range parts {
+				selina.FreeBuffer(p)
 			}
-			b, err := codec(res)
+			parts = parts[:0]
+			pending.Reset()
 			if err != nil {
-				return fmt.Errorf("encoding %w", err)
+				return err
+			}
+			if nb == nil {
+				continue
 			}
-			nb := selina.GetBuffer()
-			nb.Write(b)
 			if err := selina.SendContext(ctx, nb, args.Output); err != nil {
 				return err
 			}
@@ -207,7 +485,155 @@ func (d *Decoder) Process(ctx context.Context, args selina.ProcessArgs) error {
 	}
 }
 
+// openQuote returns true if data ends inside a quoted field, like csv.Reader
+// only a field that begins with a quote is quoted and escaped quotes are doubled,
+// with lazy a quote that is not followed by comma or end of line is data
+func openQuote(data []byte, comma rune, lazy bool) bool {
+	sep := []byte(string(comma))
+	quoted, start := false, true
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if quoted {
+			if c != '"' {
+				continue
+			}
+			next := data[i+1:]
+			switch {
+			case len(next) > 0 && next[0] == '"':
+				i++
+			case !lazy, len(next) == 0, next[0] == '\n', next[0] == '\r', bytes.HasPrefix(next, sep):
+				quoted = false
+			}
+			continue
+		}
+		switch {
+		case c == '"' && start:
+			quoted = true
+		case bytes.HasPrefix(data[i:], sep):
+			start = true
+			i += len(sep) - 1
+			continue
+		case c == '\n':
+			start = true
+			continue
+		}
+		start = false
+	}
+	return quoted
+}
+
+// forwardAll track out as a message derived from all parts, every part
+// is acknowledged when out is acknowledged (see selina.Forward)
+func forwardAll(parts []*bytes.Buffer, out *bytes.Buffer) {
+	if len(parts) == 1 {
+		selina.Forward(parts[0], out)
+		return
+	}
+	var dones []func(error)
+	for _, p := range parts {
+		if selina.IsTracked(p) {
+			dones = append(dones, selina.Detach(p))
+		}
+	}
+	if len(dones) == 0 {
+		return
+	}
+	selina.OnAck(out, func(err error) {
+		for _, done := range dones {
+			done(err)
+		}
+	})
+}
+
 // NewDecoder return a new csv decoder with given options
 func NewDecoder(opts DecoderOptions) *Decoder {
 	return &Decoder{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (e *Encoder) Describe() (string, map[string]interface{}, error) {
+	if e.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "encode"}
+	if len(e.opts.Header) > 0 {
+		args["header"] = e.opts.Header
+	}
+	if e.opts.Comma != 0 {
+		args["comma"] = e.opts.Comma
+	}
+	if e.opts.UseCRLF {
+		args["crlf"] = true
+	}
+	if e.opts.Null != "" {
+		args["null_text"] = e.opts.Null
+	}
+	if e.opts.True != "" {
+		args["true_text"] = e.opts.True
+	}
+	if e.opts.False != "" {
+		args["false_text"] = e.opts.False
+	}
+	if e.opts.FloatPrecision > 0 {
+		args["float_precision"] = e.opts.FloatPrecision
+	}
+	if e.opts.TimeLayout != "" {
+		args["time_layout"] = e.opts.TimeLayout
+	}
+	if e.opts.Unknown != "" {
+		args["unknown_fields"] = string(e.opts.Unknown)
+	}
+	if e.opts.SpillColumn != "" {
+		args["spill_column"] = e.opts.SpillColumn
+	}
+	if e.opts.NoHeader {
+		args["no_header"] = true
+	}
+	if err := selina.DescribeFormat(args, "read_format", e.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	return "csv", args, nil
+}
+
+// Describe implements selina.Describer interface
+func (d *Decoder) Describe() (string, map[string]interface{}, error) {
+	if d.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "decode"}
+	if len(d.opts.Header) > 0 {
+		args["header"] = d.opts.Header
+	}
+	if d.opts.Comma != 0 {
+		args["comma"] = d.opts.Comma
+	}
+	if d.opts.Comment != 0 {
+		args["comment"] = d.opts.Comment
+	}
+	if d.opts.HeaderFromFirst {
+		args["header_from_first"] = true
+	}
+	if d.opts.Ragged != "" {
+		args["ragged"] = string(d.opts.Ragged)
+	}
+	if d.opts.InferTypes {
+		args["infer_types"] = true
+	}
+	if d.opts.MultiLine {
+		args["multi_line"] = true
+	}
+	if d.opts.LazyQuotes {
+		args["lazy_quotes"] = true
+	}
+	if len(d.opts.Types) > 0 {
+		types := make(map[string]string, len(d.opts.Types))
+		for col, t := range d.opts.Types {
+			types[col] = string(t)
+		}
+		args["types"] = types
+	}
+	if err := selina.DescribeFormat(args, "write_format", d.opts.Codec); err != nil {
+		return "", nil, err
+	}
+	return "csv", args, nil
+}
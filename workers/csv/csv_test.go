@@ -8,6 +8,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/licaonfee/selina"
 	"github.com/licaonfee/selina/workers"
@@ -64,6 +65,49 @@ func TestEncoderProcess(t *testing.T) {
 			want:    []string{`name,id,color` + "\n", `Selina,0,yellow` + "\n", `Lizbeth,1,` + "\n"},
 			wantErr: nil,
 		},
+		{
+			name:  "Types",
+			opts:  csv.EncoderOptions{Header: []string{"ok", "none", "tags", "meta", "ratio"}},
+			input: []string{`{"ok":true,"none":null,"tags":["a","b"],"meta":{"x":1},"ratio":0.5}`},
+			want:  []string{`ok,none,tags,meta,ratio` + "\n", `true,,"[""a"",""b""]","{""x"":1}",0.5` + "\n"},
+		},
+		{
+			name:  "Custom formats",
+			opts:  csv.EncoderOptions{Header: []string{"ok", "ko", "none", "ratio"}, Null: "NULL", True: "Y", False: "N", FloatPrecision: 2},
+			input: []string{`{"ok":true,"ko":false,"none":null,"ratio":0.5}`},
+			want:  []string{`ok,ko,none,ratio` + "\n", `Y,N,NULL,0.50` + "\n"},
+		},
+		{
+			name:  "Unknown fields ignored",
+			opts:  csv.EncoderOptions{Unknown: csv.UnknownIgnore},
+			input: []string{`{"id":0}`, `{"id":1,"name":"Lizbeth"}`},
+			want:  []string{`id` + "\n", `0` + "\n", `1` + "\n"},
+		},
+		{
+			name:    "Unknown fields strict",
+			opts:    csv.EncoderOptions{Header: []string{"id"}, Unknown: csv.UnknownStrict, Handler: func(error) bool { return true }},
+			input:   []string{`{"id":0}`, `{"id":1,"name":"Lizbeth"}`},
+			want:    []string{`id` + "\n", `0` + "\n"},
+			wantErr: csv.ErrUnknownField,
+		},
+		{
+			name:  "Unknown fields spilled",
+			opts:  csv.EncoderOptions{Unknown: csv.UnknownSpill},
+			input: []string{`{"id":0}`, `{"id":1,"name":"Lizbeth","tags":["a"]}`},
+			want:  []string{`id,_extra` + "\n", `0,` + "\n", `1,"{""name"":""Lizbeth"",""tags"":[""a""]}"` + "\n"},
+		},
+		{
+			name:  "Custom spill column",
+			opts:  csv.EncoderOptions{Header: []string{"id"}, Unknown: csv.UnknownSpill, SpillColumn: "rest"},
+			input: []string{`{"id":0,"x":1}`},
+			want:  []string{`id,rest` + "\n", `0,"{""x"":1}"` + "\n"},
+		},
+		{
+			name:  "No header",
+			opts:  csv.EncoderOptions{Header: []string{"name", "id"}, NoHeader: true},
+			input: []string{`{"name": "Selina","id":0}`, `{"name":"Lizbeth","id":1}`},
+			want:  []string{`Selina,0` + "\n", `Lizbeth,1` + "\n"},
+		},
 		{
 			name:    "Invalid JSON",
 			opts:    csv.EncoderOptions{Header: []string{"name", "id", "color"}},
@@ -102,6 +146,61 @@ func TestEncoderProcess(t *testing.T) {
 	}
 }
 
+func TestEncoderProcessNativeTypes(t *testing.T) {
+	ts := time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+	// binary codecs like msgpack decode integers, times and maps with any key
+	rf := func(_ []byte, v interface{}) error {
+		*(v.(*map[string]interface{})) = map[string]interface{}{
+			"n": int64(-3), "u": uint8(7), "t": ts, "m": map[interface{}]interface{}{"k": int32(1)},
+		}
+		return nil
+	}
+	c := csv.NewEncoder(csv.EncoderOptions{Header: []string{"n", "u", "t", "m"}, ReadFormat: rf, TimeLayout: time.DateOnly})
+	output := make(chan *bytes.Buffer, 2)
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer([]string{"x"}, true), Output: output}
+	if err := c.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	got := selina.ChannelAsSlice(output)
+	if len(got) != 2 || got[1].String() != `-3,7,2022-01-01,"{""k"":1}"`+"\n" {
+		t.Fatalf("Process() got = %v", got)
+	}
+}
+
+func TestEncoderProcessUnknownStrict(t *testing.T) {
+	c := csv.NewEncoder(csv.EncoderOptions{Header: []string{"id"}, Unknown: csv.UnknownStrict})
+	output := make(chan *bytes.Buffer, 2)
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer([]string{`{"id":1,"name":"a"}`}, true), Output: output}
+	if err := c.Process(context.Background(), args); !errors.Is(err, csv.ErrUnknownField) {
+		t.Fatalf("Process() err = %v", err)
+	}
+}
+
+func TestEncoderProcessInvalidOptions(t *testing.T) {
+	c := csv.NewEncoder(csv.EncoderOptions{Unknown: "drop"})
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer([]string{`{"id":1}`}, true), Output: make(chan *bytes.Buffer, 2)}
+	if err := c.Process(context.Background(), args); err == nil {
+		t.Fatal("Process() must fail with invalid options")
+	}
+}
+
+func TestEncoderProcessAutoHeader(t *testing.T) {
+	// header taken from first record must not change encoder options
+	c := csv.NewEncoder(csv.EncoderOptions{})
+	output := make(chan *bytes.Buffer, 2)
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer([]string{`{"id":1}`}, true), Output: output}
+	if err := c.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	_, desc, err := c.Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	if _, ok := desc["header"]; ok {
+		t.Fatalf("Describe() got = %v, header was inferred", desc)
+	}
+}
+
 func TestEncoderProcessCancelation(t *testing.T) {
 	c := csv.NewEncoder(csv.EncoderOptions{})
 	if err := workers.ATProcessCancel(c); err != nil {
@@ -122,6 +221,38 @@ func TestEncoderProcessCloseOutput(t *testing.T) {
 	}
 }
 
+func TestEncoderProcessNilUpstream(t *testing.T) {
+	if err := workers.ATProcessNilUpstream(csv.NewEncoder(csv.EncoderOptions{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessErrorHandler(t *testing.T) {
+	newEncoder := func(h selina.ErrorHandler) selina.Worker {
+		return csv.NewEncoder(csv.EncoderOptions{Header: []string{"name"}, Handler: h})
+	}
+	if err := workers.ATProcessErrorHandler(newEncoder, []string{`{"name":"a"}`, `{"name"`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessFreeBuffers(t *testing.T) {
+	w := csv.NewEncoder(csv.EncoderOptions{Header: []string{"name"}})
+	if err := workers.ATProcessFreeBuffers(w, []string{`{"name":"a"}`, `{"name":"b"}`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzEncoder(f *testing.F) {
+	f.Add([]byte(`{"name":"a","id":1}` + "\n" + `{"name":"b"}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w := csv.NewEncoder(csv.EncoderOptions{Handler: func(error) bool { return true }})
+		if err := workers.ATProcessFuzz(w, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
 func TestDecoderProcess(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -173,6 +304,87 @@ func TestDecoderProcess(t *testing.T) {
 			want:    []string{`{"id":"6","name":"Selina"}`},
 			wantErr: ecsv.ErrFieldCount,
 		},
+		{
+			name:    "more columns than header",
+			opts:    csv.DecoderOptions{Header: []string{"id"}},
+			input:   []string{`6,Selina`},
+			want:    []string{},
+			wantErr: csv.ErrRaggedRow,
+		},
+		{
+			name:  "header from first record",
+			opts:  csv.DecoderOptions{HeaderFromFirst: true},
+			input: []string{`id,name`, `6,Selina`},
+			want:  []string{`{"id":"6","name":"Selina"}`},
+		},
+		{
+			name:  "skip first record",
+			opts:  csv.DecoderOptions{HeaderFromFirst: true, Header: []string{"a", "b"}},
+			input: []string{`id,name`, `6,Selina`},
+			want:  []string{`{"a":"6","b":"Selina"}`},
+		},
+		{
+			name:  "ragged pad",
+			opts:  csv.DecoderOptions{Header: []string{"id", "name"}, Ragged: csv.RaggedPad},
+			input: []string{`6`, `7,Lizbeth,yellow`},
+			want:  []string{`{"id":"6","name":null}`, `{"column_3":"yellow","id":"7","name":"Lizbeth"}`},
+		},
+		{
+			name:  "ragged truncate",
+			opts:  csv.DecoderOptions{Header: []string{"id", "name"}, Ragged: csv.RaggedTruncate},
+			input: []string{`6`, `7,Lizbeth,yellow`},
+			want:  []string{`{"id":"6"}`, `{"id":"7","name":"Lizbeth"}`},
+		},
+		{
+			name:    "ragged error",
+			opts:    csv.DecoderOptions{Header: []string{"id", "name"}, Ragged: csv.RaggedError},
+			input:   []string{`6,Selina`, `7`},
+			want:    []string{`{"id":"6","name":"Selina"}`},
+			wantErr: csv.ErrRaggedRow,
+		},
+		{
+			name:  "infer types",
+			opts:  csv.DecoderOptions{Header: []string{"id", "ratio", "ok", "zip", "name"}, InferTypes: true},
+			input: []string{`6,0.5,true,01234,NaN`},
+			want:  []string{`{"id":6,"name":"NaN","ok":true,"ratio":0.5,"zip":"01234"}`},
+		},
+		{
+			name:  "declared types",
+			opts:  csv.DecoderOptions{Header: []string{"id", "ratio", "ok"}, Types: map[string]csv.ColumnType{"id": csv.TypeString, "ratio": csv.TypeFloat, "ok": csv.TypeBool}, InferTypes: true},
+			input: []string{`6,,1`},
+			want:  []string{`{"id":"6","ok":true,"ratio":null}`},
+		},
+		{
+			name:  "invalid declared type",
+			opts:  csv.DecoderOptions{Header: []string{"id"}, Types: map[string]csv.ColumnType{"id": csv.TypeInt}, Handler: func(error) bool { return true }},
+			input: []string{`six`, `7`},
+			want:  []string{`{"id":7}`},
+		},
+		{
+			name:  "multi-line field",
+			opts:  csv.DecoderOptions{Header: []string{"id", "note"}, MultiLine: true},
+			input: []string{`1,"first`, ``, `third ""quoted"""`, `2,plain`},
+			want:  []string{`{"id":"1","note":"first\n\nthird \"quoted\""}`, `{"id":"2","note":"plain"}`},
+		},
+		{
+			name:  "multi-line stray quote in unquoted field",
+			opts:  csv.DecoderOptions{Header: []string{"id", "note"}, MultiLine: true, LazyQuotes: true},
+			input: []string{`1,5" screen`, `2,plain`},
+			want:  []string{`{"id":"1","note":"5\" screen"}`, `{"id":"2","note":"plain"}`},
+		},
+		{
+			name:  "multi-line lazy quoted field",
+			opts:  csv.DecoderOptions{Header: []string{"id", "note"}, MultiLine: true, LazyQuotes: true},
+			input: []string{`1,"say "hi`, `there"`, `2,plain`},
+			want:  []string{`{"id":"1","note":"say \"hi\nthere"}`, `{"id":"2","note":"plain"}`},
+		},
+		{
+			name:    "multi-line field not closed",
+			opts:    csv.DecoderOptions{Header: []string{"id", "note"}, MultiLine: true},
+			input:   []string{`1,one`, `2,"two`},
+			want:    []string{`{"id":"1","note":"one"}`},
+			wantErr: csv.ErrOpenQuote,
+		},
 		{
 			name:    "malformed csv hanlded",
 			opts:    csv.DecoderOptions{Header: []string{"id", "name"}, Handler: func(error) bool { return true }},
@@ -207,8 +419,8 @@ func TestDecoderProcess(t *testing.T) {
 }
 
 func TestDecoderProcessCancelation(t *testing.T) {
-	c := csv.NewDecoder(csv.DecoderOptions{})
-	if err := workers.ATProcessCancel(c); err != nil {
+	c := csv.NewDecoder(csv.DecoderOptions{Header: []string{"id"}})
+	if err := workers.ATProcessCancel(c, "1", "2"); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -225,3 +437,93 @@ func TestDecoderProcessCloseOutput(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDecoderProcessOrder(t *testing.T) {
+	w := csv.NewDecoder(csv.DecoderOptions{Header: []string{"id"}})
+	in := []string{"1", "2", "3"}
+	want := []string{`{"id":"1"}`, `{"id":"2"}`, `{"id":"3"}`}
+	if err := workers.ATProcessOrder(w, in, want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add([]byte("a,b\n\"quoted,\",c"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w := csv.NewDecoder(csv.DecoderOptions{Header: []string{"a", "b"}, Handler: func(error) bool { return true }})
+		if err := workers.ATProcessFuzz(w, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestDecoderDescribe(t *testing.T) {
+	d := csv.NewDecoder(csv.DecoderOptions{Header: []string{"a"}, Comma: ';', Codec: json.Marshal})
+	typ, args, err := d.Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	want := map[string]interface{}{"mode": "decode", "header": []string{"a"}, "comma": ';', "write_format": "json"}
+	if typ != "csv" || !reflect.DeepEqual(args, want) {
+		t.Fatalf("Describe() got = %s %v, want %v", typ, args, want)
+	}
+}
+
+func TestEncoderDescribe(t *testing.T) {
+	e := csv.NewEncoder(csv.EncoderOptions{Header: []string{"a"}, Null: "NULL", FloatPrecision: 2})
+	typ, args, err := e.Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	want := map[string]interface{}{"mode": "encode", "header": []string{"a"}, "null_text": "NULL", "float_precision": 2}
+	if typ != "csv" || !reflect.DeepEqual(args, want) {
+		t.Fatalf("Describe() got = %s %v, want %v", typ, args, want)
+	}
+}
+
+func TestEncoderOptionsCheck(t *testing.T) {
+	if err := (csv.EncoderOptions{Unknown: "drop"}).Check(); err == nil {
+		t.Fatal("Check() must fail with an invalid unknown fields mode")
+	}
+	if err := (csv.EncoderOptions{SpillColumn: "rest"}).Check(); err == nil {
+		t.Fatal("Check() must fail with a spill column without spill mode")
+	}
+	if err := (csv.EncoderOptions{Unknown: csv.UnknownSpill, SpillColumn: "rest"}).Check(); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
+
+func TestDecoderOptionsCheck(t *testing.T) {
+	if err := (csv.DecoderOptions{Ragged: "drop"}).Check(); err == nil {
+		t.Fatal("Check() must fail with an invalid ragged mode")
+	}
+	if err := (csv.DecoderOptions{Types: map[string]csv.ColumnType{"id": "date"}}).Check(); err == nil {
+		t.Fatal("Check() must fail with an invalid column type")
+	}
+}
+
+func TestDecoderProcessMultiLineAck(t *testing.T) {
+	var acks []error
+	input := make(chan *bytes.Buffer, 2)
+	for _, line := range []string{`1,"first`, `second"`} {
+		msg := selina.GetBuffer()
+		msg.WriteString(line)
+		selina.OnAck(msg, func(err error) { acks = append(acks, err) })
+		input <- msg
+	}
+	close(input)
+	output := make(chan *bytes.Buffer, 1)
+	d := csv.NewDecoder(csv.DecoderOptions{Header: []string{"id", "note"}, MultiLine: true})
+	if err := d.Process(context.Background(), selina.ProcessArgs{Input: input, Output: output}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	out := <-output
+	if len(acks) != 0 {
+		t.Fatalf("parts acknowledged before output, acks = %v", acks)
+	}
+	selina.Ack(out, nil)
+	selina.FreeBuffer(out)
+	if len(acks) != 2 || acks[0] != nil || acks[1] != nil {
+		t.Fatalf("acks = %v", acks)
+	}
+}
@@ -0,0 +1,115 @@
+package csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Formatter convert record values into text cells, it is used by csv.Encoder
+// and can be shared by other text codecs, zero value is ready to use
+type Formatter struct {
+	// Null text of null values, default is an empty cell
+	Null string
+	// True text of true booleans, default true
+	True string
+	// False text of false booleans, default false
+	False string
+	// FloatPrecision decimals of float values, default is the shortest
+	// representation that keeps its value
+	FloatPrecision int
+	// TimeLayout format of time values, default time.RFC3339Nano
+	TimeLayout string
+}
+
+func newFormatter(opts EncoderOptions) Formatter {
+	return Formatter{Null: opts.Null, True: opts.True, False: opts.False,
+		FloatPrecision: opts.FloatPrecision, TimeLayout: opts.TimeLayout}
+}
+
+// Format returns the cell of value, nested values are encoded as json
+func (f Formatter) Format(value interface{}) (string, error) {
+	precision := -1
+	if f.FloatPrecision > 0 {
+		precision = f.FloatPrecision
+	}
+	switch v := value.(type) {
+	case nil:
+		return f.Null, nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bool:
+		if v && f.True != "" {
+			return f.True, nil
+		}
+		if !v && f.False != "" {
+			return f.False, nil
+		}
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', precision, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', precision, 32), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case json.Number:
+		return v.String(), nil
+	case time.Time:
+		if f.TimeLayout != "" {
+			return v.Format(f.TimeLayout), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	}
+	b, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return "", fmt.Errorf("format %T: %w", value, err)
+	}
+	return string(b), nil
+}
+
+// jsonValue convert maps with non string keys, as decoded by yaml
+// or msgpack, into values that json.Marshal accepts
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = jsonValue(e)
+		}
+		return s
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package csv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is the type of a decoded column
+type ColumnType string
+
+const (
+	// TypeString keep cell as is
+	TypeString ColumnType = "string"
+	// TypeInt decode cell as an integer
+	TypeInt ColumnType = "int"
+	// TypeFloat decode cell as a float
+	TypeFloat ColumnType = "float"
+	// TypeBool decode cell as a boolean, see strconv.ParseBool
+	TypeBool ColumnType = "bool"
+)
+
+// Check if t is a valid type
+func (t ColumnType) Check() error {
+	switch t {
+	case TypeString, TypeInt, TypeFloat, TypeBool:
+		return nil
+	}
+	return fmt.Errorf("invalid column type '%s'", t)
+}
+
+// Parse convert cell into t, empty cells are null
+func (t ColumnType) Parse(cell string) (interface{}, error) {
+	if t == TypeString {
+		return cell, nil
+	}
+	if cell == "" {
+		return nil, nil
+	}
+	var v interface{}
+	var err error
+	switch t {
+	case TypeInt:
+		v, err = strconv.ParseInt(cell, 10, 64)
+	case TypeFloat:
+		v, err = strconv.ParseFloat(cell, 64)
+	case TypeBool:
+		v, err = strconv.ParseBool(cell)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s'", t, cell)
+	}
+	return v, nil
+}
+
+// infer returns cell as a number or a boolean when it looks like one,
+// numbers with leading zeros are kept as strings (i.e. zip codes)
+func infer(cell string) interface{} {
+	switch cell {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	digits := strings.TrimLeft(cell, "+-")
+	if digits == "" || len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return cell
+	}
+	if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		return i
+	}
+	if digits[0] < '0' || digits[0] > '9' {
+		// Inf, NaN and hexadecimal floats are not numbers in csv files
+		return cell
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil && !math.IsInf(f, 0) {
+		return f
+	}
+	return cell
+}
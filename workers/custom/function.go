@@ -14,13 +14,15 @@ var _ selina.Worker = (*Function)(nil)
 
 // UserFunction define an user custom modification
 // is safe to return input to avoid allocations
-// if an error is returned Process is aborted
+// if an error is returned node ErrorPolicy is applied (fail by default)
 // a filter can be implemented returning (nil,nil)
 type UserFunction func(input []byte) ([]byte, error)
 
 // FunctionOptions customize a Function Worker
 type FunctionOptions struct {
 	Func UserFunction
+	// Handler is called on Func errors before node ErrorPolicy
+	Handler selina.ErrorHandler
 }
 
 // Check if a combination of options is valid
@@ -52,14 +54,25 @@ func (f *Function) Process(ctx context.Context, args selina.ProcessArgs) error {
 			if !ok {
 				return nil
 			}
+			// message is modified in place so a private copy is required
+			msg = selina.Own(msg)
 			data.Reset()
 			_, _ = io.Copy(data, msg)
-			omsg, err := f.opts.Func(data.Bytes())
+			var omsg []byte
+			var filtered bool
+			err := selina.HandleMessage(ctx, data.Bytes(), f.opts.Handler, func() (err error) {
+				omsg, err = f.opts.Func(data.Bytes())
+				filtered = err == nil && omsg == nil
+				return err
+			})
 			if err != nil {
 				selina.FreeBuffer(msg)
 				return err
 			}
 			if omsg == nil {
+				if filtered {
+					selina.Ack(msg, nil)
+				}
 				selina.FreeBuffer(msg)
 				continue
 			}
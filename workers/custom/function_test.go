@@ -37,6 +37,21 @@ func TestFunctionProcessCancel(t *testing.T) {
 	}
 }
 
+func TestFunctionProcessOrder(t *testing.T) {
+	f := custom.NewFunction(custom.FunctionOptions{Func: pass})
+	in := []string{"a", "b", "c"}
+	if err := workers.ATProcessOrder(f, in, in); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFunctionProcessNoLeak(t *testing.T) {
+	f := custom.NewFunction(custom.FunctionOptions{Func: pass})
+	if err := workers.ATProcessNoLeak(f, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestFunctionProcess(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -9,6 +9,7 @@ import (
 	"io"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/compress"
 	"github.com/spf13/afero"
 )
 
@@ -30,10 +31,14 @@ func (f FilenameFunc) Filename([]byte) string {
 
 // ReaderOptions configuration for Reader worker
 type ReaderOptions struct {
-	Fs        afero.Fs
+	Fs afero.Fs
+	// SplitFunc used to tokenize files, default is bufio.ScanLines
 	SplitFunc bufio.SplitFunc
 	Filename  Filenamer
 	Handler   selina.ErrorHandler
+	// Compression decompress files, compress.Auto use file extension
+	// and fallback to magic bytes, default is no compression
+	Compression compress.Format
 }
 
 // Reader for every message received it call Filenamer.Filename(msg)
@@ -55,10 +60,6 @@ func (r *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 			err = e
 		}
 	}()
-	errHandler := selina.DefaultErrorHandler
-	if r.opts.Handler != nil {
-		errHandler = r.opts.Handler
-	}
 	for {
 		select {
 		case msg, ok := <-args.Input:
@@ -66,19 +67,36 @@ func (r *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 				return nil
 			}
 			fname := r.opts.Filename.Filename(msg.Bytes())
+			var file io.Closer
+			var rd io.ReadCloser
+			err := selina.HandleMessage(ctx, msg.Bytes(), r.opts.Handler, func() error {
+				f, err := r.opts.Fs.Open(fname)
+				if err != nil {
+					return fmt.Errorf("Process was unable to open file from fs %w", err)
+				}
+				d, err := r.decompress(fname, f)
+				if err != nil {
+					_ = f.Close()
+					return err
+				}
+				file, rd = f, d
+				return nil
+			})
 			selina.FreeBuffer(msg)
-			file, err := r.opts.Fs.Open(fname)
-			switch {
-			case err == nil:
-			case errHandler(err):
+			if err != nil {
+				return err
+			}
+			if rd == nil {
 				continue
-			default:
-				return fmt.Errorf("Process was unable to open file from fs %w", err)
 			}
 			currFile = file
-			sc := bufio.NewScanner(file)
-			sc.Split(r.opts.SplitFunc)
-			if err := readFile(ctx, sc, args.Output); err != nil {
+			sc := bufio.NewScanner(rd)
+			if r.opts.SplitFunc != nil {
+				sc.Split(r.opts.SplitFunc)
+			}
+			err = readFile(ctx, sc, args.Output)
+			_ = rd.Close()
+			if err != nil {
 				return err
 			}
 			currFile = nil
@@ -92,6 +110,16 @@ func (r *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 	}
 }
 
+func (r *Reader) decompress(fname string, file io.Reader) (io.ReadCloser, error) {
+	format := r.opts.Compression
+	if format == compress.Auto {
+		if f := compress.FromFilename(fname); f != compress.None {
+			format = f
+		}
+	}
+	return compress.NewReader(file, format)
+}
+
 func readFile(ctx context.Context, sc *bufio.Scanner, out chan<- *bytes.Buffer) error {
 	for sc.Scan() {
 		msg := selina.GetBuffer()
@@ -99,10 +127,11 @@ func readFile(ctx context.Context, sc *bufio.Scanner, out chan<- *bytes.Buffer)
 		select {
 		case out <- msg:
 		case <-ctx.Done():
+			selina.FreeBuffer(msg)
 			return ctx.Err()
 		}
 	}
-	return nil
+	return sc.Err()
 }
 
 // NewReader create a new reader with goven options
@@ -3,14 +3,17 @@ package filesystem_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/licaonfee/selina"
 	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/compress"
 	fs "github.com/licaonfee/selina/workers/filesystem"
 	"github.com/spf13/afero"
 )
@@ -110,9 +113,56 @@ func TestReaderProcess(t *testing.T) {
 	}
 }
 
+func TestReaderProcessCompressed(t *testing.T) {
+	data := &bytes.Buffer{}
+	gz := gzip.NewWriter(data)
+	_, _ = gz.Write([]byte("some data\nin the file"))
+	_ = gz.Close()
+	files := map[string]string{
+		"/tmp/my.csv.gz": data.String(),
+		"/tmp/noext":     data.String(),
+		"/tmp/plain.csv": "plain\ntext",
+	}
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{name: "by extension", in: []string{"/tmp/my.csv.gz"}, want: []string{"some data", "in the file"}},
+		{name: "by magic bytes", in: []string{"/tmp/noext"}, want: []string{"some data", "in the file"}},
+		{name: "not compressed", in: []string{"/tmp/plain.csv"}, want: []string{"plain", "text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := fs.NewReader(fs.ReaderOptions{
+				Filename:    &nameFromBytes{},
+				Fs:          populateFs(files),
+				SplitFunc:   bufio.ScanLines,
+				Compression: compress.Auto,
+			})
+			input := selina.SliceAsChannelOfBuffer(tt.in, true)
+			output := make(chan *bytes.Buffer, len(tt.want))
+			args := selina.ProcessArgs{Input: input, Output: output}
+			if err := r.Process(context.Background(), args); err != nil {
+				t.Fatalf("Process() err = %v", err)
+			}
+			got := []string{}
+			for _, b := range selina.ChannelAsSlice(output) {
+				got = append(got, b.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Process() got = %v , want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestReaderProcessCancelation(t *testing.T) {
-	r := fs.NewReader(fs.ReaderOptions{})
-	if err := workers.ATProcessCancel(r); err != nil {
+	r := fs.NewReader(fs.ReaderOptions{
+		Fs:       populateFs(map[string]string{"/tmp/big.txt": strings.Repeat("line\n", 1000)}),
+		Filename: nameFromBytes{},
+	})
+	if err := workers.ATProcessCancel(r, "/tmp/big.txt"); err != nil {
 		t.Fatal(err)
 	}
 }
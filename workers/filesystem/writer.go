@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/compress"
 	"github.com/spf13/afero"
 )
 
@@ -18,6 +19,30 @@ type WriterOptions struct {
 	BufferSize int
 	Mode       os.FileMode
 	Handler    selina.ErrorHandler
+	// Compression compress every file written, default is no compression
+	Compression compress.Format
+	// Header first message is a header (i.e. csv.Encoder output), it is not
+	// passed to Filename and it is written at the top of every file
+	Header bool
+}
+
+// Check if a combination of options is valid
+func (o WriterOptions) Check() error {
+	return o.Compression.CheckWrite()
+}
+
+// compressedFile close compressor before underlying file
+type compressedFile struct {
+	io.WriteCloser
+	file io.Closer
+}
+
+func (c *compressedFile) Close() error {
+	err := c.WriteCloser.Close()
+	if errFile := c.file.Close(); err == nil {
+		err = errFile
+	}
+	return err
 }
 
 type Writer struct {
@@ -27,8 +52,13 @@ type Writer struct {
 // Process implents selina.Worker interface
 func (w Writer) Process(ctx context.Context, args selina.ProcessArgs) (err error) {
 	defer close(args.Output)
+	if err := w.opts.Check(); err != nil {
+		return err
+	}
 	var currFname string
 	var currFile io.WriteCloser
+	var header []byte
+	needHeader := w.opts.Header
 	if w.opts.Mode == 0 {
 		w.opts.Mode = 0600
 	}
@@ -40,44 +70,56 @@ func (w Writer) Process(ctx context.Context, args selina.ProcessArgs) (err error
 			err = e
 		}
 	}()
-	errHandler := selina.DefaultErrorHandler
-	if w.opts.Handler != nil {
-		errHandler = w.opts.Handler
-	}
 	for {
 		select {
 		case msg, ok := <-args.Input:
 			if !ok {
 				return nil
 			}
+			if needHeader {
+				needHeader = false
+				header = append([]byte(nil), msg.Bytes()...)
+				selina.Ack(msg, nil)
+				selina.FreeBuffer(msg)
+				continue
+			}
 			fname := w.opts.Filename.Filename(msg.Bytes())
 			if fname != currFname {
-				if currFile != nil {
-					if err := currFile.Close(); !errHandler(err) {
-						return fmt.Errorf("closing file %w", err)
+				err := selina.HandleMessage(ctx, msg.Bytes(), w.opts.Handler, func() error {
+					if currFile != nil {
+						err := currFile.Close()
+						currFile, currFname = nil, ""
+						if err != nil {
+							return fmt.Errorf("closing file %w", err)
+						}
 					}
-				}
-				f, err := w.opts.Fs.Create(fname)
-				switch {
-				case err == nil:
-				case errHandler(err):
-					continue
-				default:
+					f, err := w.open(fname)
+					if err != nil {
+						return err
+					}
+					if _, err := f.Write(header); err != nil {
+						_ = f.Close()
+						return fmt.Errorf("writing header %w", err)
+					}
+					currFile, currFname = f, fname
+					return nil
+				})
+				if err != nil {
+					selina.FreeBuffer(msg)
 					return err
 				}
-				err = w.opts.Fs.Chmod(fname, w.opts.Mode)
-				switch {
-				case err == nil:
-				case errHandler(err):
+				if currFile == nil {
+					selina.FreeBuffer(msg)
 					continue
-				default:
-					return err
 				}
-				currFile = f
-				currFname = fname
 			}
 
-			if _, err := io.Copy(currFile, msg); err != nil {
+			_, err := currFile.Write(msg.Bytes())
+			if err == nil {
+				selina.Ack(msg, nil)
+			}
+			selina.FreeBuffer(msg)
+			if err != nil {
 				return err
 			}
 		case <-ctx.Done():
@@ -86,6 +128,23 @@ func (w Writer) Process(ctx context.Context, args selina.ProcessArgs) (err error
 	}
 }
 
+func (w Writer) open(fname string) (io.WriteCloser, error) {
+	f, err := w.opts.Fs.Create(fname)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.opts.Fs.Chmod(fname, w.opts.Mode); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	cw, err := compress.NewWriter(f, w.opts.Compression)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &compressedFile{WriteCloser: cw, file: f}, nil
+}
+
 // NewWriter create a new writer with given options
 func NewWriter(opts WriterOptions) *Writer {
 	return &Writer{opts: opts}
@@ -2,6 +2,7 @@ package filesystem_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/licaonfee/selina"
 	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/compress"
 	fs "github.com/licaonfee/selina/workers/filesystem"
 	"github.com/spf13/afero"
 )
@@ -76,6 +78,19 @@ func TestWriterProcess(t *testing.T) {
 				"/tmp/otherfile.txt": "/tmp/otherfile.txt"}),
 			wantErr: nil,
 		},
+		{
+			name: "header in every file",
+			opts: fs.WriterOptions{
+				Filename: &nameFromBytes{},
+				Fs:       afero.NewMemMapFs(),
+				Header:   true,
+			},
+			in: []string{"id\n", "/tmp/01.txt", "/tmp/02.txt"},
+			want: populateFs(map[string]string{
+				"/tmp/01.txt": "id\n/tmp/01.txt",
+				"/tmp/02.txt": "id\n/tmp/02.txt"}),
+			wantErr: nil,
+		},
 		{
 			name: "cannot open file",
 			opts: fs.WriterOptions{
@@ -121,8 +136,49 @@ func TestWriterProcess(t *testing.T) {
 	}
 }
 
+func TestWriterProcessCompressed(t *testing.T) {
+	mfs := afero.NewMemMapFs()
+	w := fs.NewWriter(fs.WriterOptions{
+		Filename:    &nameFromBytes{},
+		Fs:          mfs,
+		Compression: compress.Gzip,
+	})
+	input := selina.SliceAsChannelOfBuffer([]string{"/tmp/01.gz", "/tmp/02.gz"}, true)
+	output := make(chan *bytes.Buffer)
+	args := selina.ProcessArgs{Input: input, Output: output}
+	if err := w.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	for _, name := range []string{"/tmp/01.gz", "/tmp/02.gz"} {
+		f, err := mfs.Open(name)
+		if err != nil {
+			t.Fatalf("Open() err = %v", err)
+		}
+		rd, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip.NewReader() err = %v", err)
+		}
+		got, _ := io.ReadAll(rd)
+		if string(got) != name {
+			t.Errorf("Process() got = %s, want = %s", got, name)
+		}
+	}
+}
+
+func TestWriterOptionsCheck(t *testing.T) {
+	if err := (fs.WriterOptions{Compression: compress.Bzip2}).Check(); !errors.Is(err, compress.ErrWriteUnsupported) {
+		t.Fatalf("Check() err = %v", err)
+	}
+	if err := (fs.WriterOptions{Compression: compress.Gzip}).Check(); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
+
 func TestWriterProcessCancelation(t *testing.T) {
-	r := fs.NewWriter(fs.WriterOptions{})
+	r := fs.NewWriter(fs.WriterOptions{
+		Fs:       afero.NewMemMapFs(),
+		Filename: fs.FilenameFunc(func() string { return "/tmp/out.txt" }),
+	})
 	if err := workers.ATProcessCancel(r); err != nil {
 		t.Fatal(err)
 	}
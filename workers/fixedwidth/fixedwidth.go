@@ -0,0 +1,394 @@
+// Package fixedwidth workers to read and write fixed-width text records
+package fixedwidth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/csv"
+)
+
+// Align of a value inside its column
+type Align string
+
+const (
+	// AlignLeft value is written first and padding after it
+	AlignLeft Align = "left"
+	// AlignRight padding is written first and value after it
+	AlignRight Align = "right"
+)
+
+// ErrEmptyLayout is returned when a layout has no columns
+var ErrEmptyLayout = errors.New("empty layout")
+
+// ErrOverflow is returned when an encoded value is wider than its column and Truncate is false
+var ErrOverflow = errors.New("value is wider than column")
+
+// Column of a record, Offset and Width are in characters
+type Column struct {
+	Name   string
+	Offset int
+	Width  int
+	// Align default AlignLeft
+	Align Align
+	// Pad character used to fill the column, default is a space,
+	// right aligned numbers padded with '0' keep its sign first (i.e. -00012)
+	Pad rune
+	// Type of decoded values, default csv.TypeString
+	Type csv.ColumnType
+}
+
+func (c Column) pad() rune {
+	if c.Pad == 0 {
+		return ' '
+	}
+	return c.Pad
+}
+
+func (c Column) signed(cell string) bool {
+	return c.Align == AlignRight && c.pad() == '0' && (strings.HasPrefix(cell, "-") || strings.HasPrefix(cell, "+"))
+}
+
+// truncable returns true when value of c can be cut, only text of string columns
+func (c Column) truncable(value interface{}) bool {
+	if c.Type != "" && c.Type != csv.TypeString {
+		return false
+	}
+	switch value.(type) {
+	case string, []byte:
+		return true
+	}
+	return false
+}
+
+// trim removes padding of cell
+func (c Column) trim(cell string) string {
+	pad := string(c.pad())
+	if c.Align != AlignRight {
+		return strings.TrimRight(cell, pad)
+	}
+	var sign string
+	if c.signed(cell) {
+		sign, cell = cell[:1], cell[1:]
+	}
+	v := strings.TrimLeft(cell, pad)
+	if v == "" && cell != "" && pad == "0" {
+		v = "0"
+	}
+	return sign + v
+}
+
+// fill pads value up to column width
+func (c Column) fill(value string) (string, error) {
+	n := utf8.RuneCountInString(value)
+	if n > c.Width {
+		return "", fmt.Errorf("%w: %s has %d characters, width is %d", ErrOverflow, c.Name, n, c.Width)
+	}
+	pad := strings.Repeat(string(c.pad()), c.Width-n)
+	switch {
+	case c.Align != AlignRight:
+		return value + pad, nil
+	case c.signed(value):
+		return value[:1] + pad + value[1:], nil
+	}
+	return pad + value, nil
+}
+
+// Layout columns of a record, gaps between columns are filled with spaces
+type Layout []Column
+
+// Check if layout is valid, columns can not overlap
+func (l Layout) Check() error {
+	if len(l) == 0 {
+		return ErrEmptyLayout
+	}
+	names := make(map[string]struct{}, len(l))
+	for _, c := range l {
+		switch {
+		case c.Name == "":
+			return errors.New("column without name")
+		case c.Offset < 0 || c.Width <= 0:
+			return fmt.Errorf("column %s: invalid offset %d or width %d", c.Name, c.Offset, c.Width)
+		case c.Align != "" && c.Align != AlignLeft && c.Align != AlignRight:
+			return fmt.Errorf("column %s: invalid align '%s'", c.Name, c.Align)
+		}
+		if c.Type != "" {
+			if err := c.Type.Check(); err != nil {
+				return fmt.Errorf("column %s: %w", c.Name, err)
+			}
+		}
+		if _, ok := names[c.Name]; ok {
+			return fmt.Errorf("duplicated column %s", c.Name)
+		}
+		names[c.Name] = struct{}{}
+	}
+	sorted := l.sorted()
+	for i := 1; i < len(sorted); i++ {
+		if prev := sorted[i-1]; sorted[i].Offset < prev.Offset+prev.Width {
+			return fmt.Errorf("column %s overlaps %s", sorted[i].Name, prev.Name)
+		}
+	}
+	return nil
+}
+
+func (l Layout) sorted() Layout {
+	s := append(Layout(nil), l...)
+	sort.Slice(s, func(i, j int) bool { return s[i].Offset < s[j].Offset })
+	return s
+}
+
+// width of a record
+func (l Layout) width() int {
+	var w int
+	for _, c := range l {
+		if end := c.Offset + c.Width; end > w {
+			w = end
+		}
+	}
+	return w
+}
+
+func (l Layout) describe() []map[string]interface{} {
+	columns := make([]map[string]interface{}, len(l))
+	for i, c := range l {
+		col := map[string]interface{}{"name": c.Name, "offset": c.Offset, "width": c.Width}
+		if c.Align != "" {
+			col["align"] = string(c.Align)
+		}
+		if c.Pad != 0 {
+			col["pad"] = string(c.Pad)
+		}
+		if c.Type != "" {
+			col["type"] = string(c.Type)
+		}
+		columns[i] = col
+	}
+	return columns
+}
+
+var _ selina.Worker = (*Encoder)(nil)
+
+// EncoderOptions configure fixed-width encoding
+type EncoderOptions struct {
+	Columns    Layout
+	ReadFormat selina.Unmarshaler
+	Handler    selina.ErrorHandler
+	// Truncate text values wider than its column instead of return ErrOverflow,
+	// numbers, booleans and nested values are never truncated because a cut
+	// value is a different one (i.e. -12345 would be -123)
+	Truncate bool
+}
+
+// Encoder transform messages into fixed-width records, missing and null
+// fields are written as padding and fields not in Columns are skipped
+type Encoder struct {
+	opts EncoderOptions
+}
+
+// NewEncoder returns a new Encoder with given options
+func NewEncoder(opts EncoderOptions) *Encoder {
+	return &Encoder{opts: opts}
+}
+
+// Process implements selina.Worker interface
+func (e *Encoder) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	if args.Input == nil {
+		return selina.ErrNilUpstream
+	}
+	if err := e.opts.Columns.Check(); err != nil {
+		return err
+	}
+	rf := selina.DefaultUnmarshaler
+	if e.opts.ReadFormat != nil {
+		rf = e.opts.ReadFormat
+	}
+	width := e.opts.Columns.width()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			var nb *bytes.Buffer
+			err := selina.HandleMessage(ctx, msg.Bytes(), e.opts.Handler, func() error {
+				data := make(map[string]interface{})
+				if err := rf(msg.Bytes(), &data); err != nil {
+					return err
+				}
+				line, err := e.record(width, data)
+				if err != nil {
+					return err
+				}
+				nb = selina.GetBuffer()
+				nb.WriteString(line)
+				return nil
+			})
+			if nb == nil {
+				selina.FreeBuffer(msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			selina.Forward(msg, nb)
+			selina.FreeBuffer(msg)
+			if err := selina.SendContext(ctx, nb, args.Output); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record returns data as a fixed-width line
+func (e *Encoder) record(width int, data map[string]interface{}) (string, error) {
+	var f csv.Formatter
+	line := []rune(strings.Repeat(" ", width))
+	for _, c := range e.opts.Columns {
+		value, err := f.Format(data[c.Name])
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		if r := []rune(value); e.opts.Truncate && len(r) > c.Width && c.truncable(data[c.Name]) {
+			value = string(r[:c.Width])
+		}
+		cell, err := c.fill(value)
+		if err != nil {
+			return "", err
+		}
+		copy(line[c.Offset:], []rune(cell))
+	}
+	return string(line), nil
+}
+
+// Describe implements selina.Describer interface
+func (e *Encoder) Describe() (string, map[string]interface{}, error) {
+	if e.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "encode", "columns": e.opts.Columns.describe()}
+	if e.opts.Truncate {
+		args["truncate"] = true
+	}
+	if err := selina.DescribeFormat(args, "read_format", e.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	return "fixed_width", args, nil
+}
+
+var _ selina.Worker = (*Decoder)(nil)
+
+// DecoderOptions configure fixed-width decoding
+type DecoderOptions struct {
+	Columns Layout
+	Codec   selina.Marshaler
+	Handler selina.ErrorHandler
+}
+
+// Decoder parse fixed-width records into key value pairs, padding is removed
+// and columns after the end of a short line are empty
+type Decoder struct {
+	opts DecoderOptions
+}
+
+// NewDecoder returns a new Decoder with given options
+func NewDecoder(opts DecoderOptions) *Decoder {
+	return &Decoder{opts: opts}
+}
+
+// Process implements selina.Worker interface
+func (d *Decoder) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	if args.Input == nil {
+		return selina.ErrNilUpstream
+	}
+	if err := d.opts.Columns.Check(); err != nil {
+		return err
+	}
+	codec := selina.DefaultMarshaler
+	if d.opts.Codec != nil {
+		codec = d.opts.Codec
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			var nb *bytes.Buffer
+			err := selina.HandleMessage(ctx, msg.Bytes(), d.opts.Handler, func() error {
+				res, err := d.record(msg.String())
+				if err != nil {
+					return err
+				}
+				b, err := codec(res)
+				if err != nil {
+					return fmt.Errorf("encoding %w", err)
+				}
+				nb = selina.GetBuffer()
+				nb.Write(b)
+				return nil
+			})
+			if nb == nil {
+				selina.FreeBuffer(msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			selina.Forward(msg, nb)
+			selina.FreeBuffer(msg)
+			if err := selina.SendContext(ctx, nb, args.Output); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record convert line into key value pairs
+func (d *Decoder) record(line string) (map[string]interface{}, error) {
+	runes := []rune(strings.TrimSuffix(line, "\r"))
+	res := make(map[string]interface{}, len(d.opts.Columns))
+	for _, c := range d.opts.Columns {
+		var cell string
+		if c.Offset < len(runes) {
+			end := c.Offset + c.Width
+			if end > len(runes) {
+				end = len(runes)
+			}
+			cell = c.trim(string(runes[c.Offset:end]))
+		}
+		t := c.Type
+		if t == "" {
+			t = csv.TypeString
+		}
+		value, err := t.Parse(cell)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		res[c.Name] = value
+	}
+	return res, nil
+}
+
+// Describe implements selina.Describer interface
+func (d *Decoder) Describe() (string, map[string]interface{}, error) {
+	if d.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "decode", "columns": d.opts.Columns.describe()}
+	if err := selina.DescribeFormat(args, "write_format", d.opts.Codec); err != nil {
+		return "", nil, err
+	}
+	return "fixed_width", args, nil
+}
@@ -0,0 +1,218 @@
+package fixedwidth_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/csv"
+	"github.com/licaonfee/selina/workers/fixedwidth"
+)
+
+// layout of a mainframe export, gap between name and amount
+var layout = fixedwidth.Layout{
+	{Name: "id", Offset: 0, Width: 4, Align: fixedwidth.AlignRight, Pad: '0', Type: csv.TypeInt},
+	{Name: "name", Offset: 4, Width: 6},
+	{Name: "amount", Offset: 12, Width: 6, Align: fixedwidth.AlignRight, Pad: '0', Type: csv.TypeInt},
+}
+
+func run(w selina.Worker, input []string) ([]string, error) {
+	output := make(chan *bytes.Buffer, len(input))
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer(input, true), Output: output}
+	err := w.Process(context.Background(), args)
+	got := []string{}
+	for _, b := range selina.ChannelAsSlice(output) {
+		got = append(got, b.String())
+	}
+	return got, err
+}
+
+func TestEncoderProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    fixedwidth.EncoderOptions
+		input   []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "Success",
+			opts:  fixedwidth.EncoderOptions{Columns: layout},
+			input: []string{`{"id":7,"name":"Selina","amount":-12}`, `{"id":12,"name":"Liz","extra":true}`},
+			want:  []string{"0007Selina  -00012", "0012Liz     000000"},
+		},
+		{
+			name:    "Overflow",
+			opts:    fixedwidth.EncoderOptions{Columns: layout},
+			input:   []string{`{"id":1,"name":"Lizbeth"}`},
+			want:    []string{},
+			wantErr: fixedwidth.ErrOverflow,
+		},
+		{
+			name:  "Truncate",
+			opts:  fixedwidth.EncoderOptions{Columns: layout, Truncate: true},
+			input: []string{`{"id":1,"name":"Lizbeth"}`},
+			want:  []string{"0001Lizbet  000000"},
+		},
+		{
+			name:    "Numbers are not truncated",
+			opts:    fixedwidth.EncoderOptions{Columns: layout, Truncate: true},
+			input:   []string{`{"id":-12345}`},
+			want:    []string{},
+			wantErr: fixedwidth.ErrOverflow,
+		},
+		{
+			name:    "Text of typed columns is not truncated",
+			opts:    fixedwidth.EncoderOptions{Columns: layout, Truncate: true},
+			input:   []string{`{"id":"12345"}`},
+			want:    []string{},
+			wantErr: fixedwidth.ErrOverflow,
+		},
+		{
+			name:    "Invalid layout",
+			opts:    fixedwidth.EncoderOptions{},
+			input:   []string{`{"id":1}`},
+			want:    []string{},
+			wantErr: fixedwidth.ErrEmptyLayout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(fixedwidth.NewEncoder(tt.opts), tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process() err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Process() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    fixedwidth.DecoderOptions
+		input   []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "Success",
+			opts:  fixedwidth.DecoderOptions{Columns: layout},
+			input: []string{"0007Selina  -00012", "0012Liz     000000\r"},
+			want:  []string{`{"amount":-12,"id":7,"name":"Selina"}`, `{"amount":0,"id":12,"name":"Liz"}`},
+		},
+		{
+			name:  "Short line",
+			opts:  fixedwidth.DecoderOptions{Columns: layout},
+			input: []string{"0001Li"},
+			want:  []string{`{"amount":null,"id":1,"name":"Li"}`},
+		},
+		{
+			name:    "Invalid number",
+			opts:    fixedwidth.DecoderOptions{Columns: layout},
+			input:   []string{"00x1Selina  000001"},
+			want:    []string{},
+			wantErr: errors.New("column id: invalid int 'x1'"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(fixedwidth.NewDecoder(tt.opts), tt.input)
+			if (err == nil) != (tt.wantErr == nil) || err != nil && err.Error() != tt.wantErr.Error() {
+				t.Fatalf("Process() err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Process() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout fixedwidth.Layout
+	}{
+		{name: "empty"},
+		{name: "no name", layout: fixedwidth.Layout{{Width: 1}}},
+		{name: "no width", layout: fixedwidth.Layout{{Name: "a"}}},
+		{name: "align", layout: fixedwidth.Layout{{Name: "a", Width: 1, Align: "center"}}},
+		{name: "type", layout: fixedwidth.Layout{{Name: "a", Width: 1, Type: "date"}}},
+		{name: "duplicated", layout: fixedwidth.Layout{{Name: "a", Width: 1}, {Name: "a", Offset: 1, Width: 1}}},
+		{name: "overlap", layout: fixedwidth.Layout{{Name: "b", Offset: 2, Width: 2}, {Name: "a", Width: 3}}},
+	}
+	for _, tt := range tests {
+		if err := tt.layout.Check(); err == nil {
+			t.Fatalf("Check() %s must fail", tt.name)
+		}
+	}
+	if err := layout.Check(); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
+
+func TestEncoderProcessCancelation(t *testing.T) {
+	if err := workers.ATProcessCancel(fixedwidth.NewEncoder(fixedwidth.EncoderOptions{Columns: layout})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessCloseInput(t *testing.T) {
+	if err := workers.ATProcessCloseInput(fixedwidth.NewEncoder(fixedwidth.EncoderOptions{Columns: layout})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessNilUpstream(t *testing.T) {
+	if err := workers.ATProcessNilUpstream(fixedwidth.NewEncoder(fixedwidth.EncoderOptions{Columns: layout})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessErrorHandler(t *testing.T) {
+	newEncoder := func(h selina.ErrorHandler) selina.Worker {
+		return fixedwidth.NewEncoder(fixedwidth.EncoderOptions{Columns: layout, Handler: h})
+	}
+	if err := workers.ATProcessErrorHandler(newEncoder, []string{`{"name":"a"}`, `{"name"`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessCancelation(t *testing.T) {
+	if err := workers.ATProcessCancel(fixedwidth.NewDecoder(fixedwidth.DecoderOptions{Columns: layout}), "0007Selina  -00012", "0012Liz     000000"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessCloseInput(t *testing.T) {
+	if err := workers.ATProcessCloseInput(fixedwidth.NewDecoder(fixedwidth.DecoderOptions{Columns: layout})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessFreeBuffers(t *testing.T) {
+	w := fixedwidth.NewDecoder(fixedwidth.DecoderOptions{Columns: layout})
+	if err := workers.ATProcessFreeBuffers(w, []string{"0001a", "0002b"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	columns := fixedwidth.Layout{{Name: "id", Width: 4, Align: fixedwidth.AlignRight, Pad: '0', Type: csv.TypeInt}}
+	_, args, err := fixedwidth.NewDecoder(fixedwidth.DecoderOptions{Columns: columns}).Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	want := map[string]interface{}{"mode": "decode", "columns": []map[string]interface{}{
+		{"name": "id", "offset": 0, "width": 4, "align": "right", "pad": "0", "type": "int"},
+	}}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("Describe() got = %v, want %v", args, want)
+	}
+}
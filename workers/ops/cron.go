@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
 
 	"github.com/licaonfee/selina"
 	"github.com/robfig/cron/v3"
@@ -21,31 +20,29 @@ type CronOptions struct {
 	Spec string
 	// Which message will be sent every schedule
 	Message []byte
+	// Clock time source, default selina.SystemClock
+	Clock selina.Clock
 }
 
 // Check if a combination of options is valid
 func (o CronOptions) Check() error {
+	_, err := o.schedule()
+	return err
+}
+
+func (o CronOptions) schedule() (cron.Schedule, error) {
 	p := cron.NewParser(cronDefaultOptions)
-	_, err := p.Parse(o.Spec)
+	s, err := p.Parse(o.Spec)
 	if err != nil {
-		return fmt.Errorf("%w %s", ErrBadCronSpec, err)
+		return nil, fmt.Errorf("%w %s", ErrBadCronSpec, err)
 	}
-	return nil
+	return s, nil
 }
 
 // Cron send an specific message at scheduled intervals
+// every Cron owns its own scheduler
 type Cron struct {
 	opts CronOptions
-	id   cron.EntryID
-}
-
-var globalCron *cron.Cron
-var initCron sync.Once
-
-func createCron() {
-	p := cron.NewParser(cronDefaultOptions)
-	globalCron = cron.New(cron.WithParser(p))
-	globalCron.Start()
 }
 
 // ErrBadCronSpec is returned when an job spec is not parseable
@@ -55,18 +52,16 @@ var ErrBadCronSpec = errors.New("bad cron spec")
 // when input is closed this worker return nil
 func (c *Cron) Process(ctx context.Context, args selina.ProcessArgs) error {
 	defer close(args.Output)
-	if err := c.opts.Check(); err != nil {
+	sched, err := c.opts.schedule()
+	if err != nil {
 		return err
 	}
-	initCron.Do(createCron)
-	tick := make(chan struct{}, 1)
-	id, _ := globalCron.AddFunc(c.opts.Spec, func() {
-		tick <- struct{}{}
-	})
-	c.id = id
-	defer func() {
-		globalCron.Remove(id)
-	}()
+	clock := c.opts.Clock
+	if clock == nil {
+		clock = selina.SystemClock
+	}
+	now := clock.Now()
+	tick := clock.After(sched.Next(now).Sub(now))
 	for {
 		select {
 		case x, ok := <-args.Input:
@@ -79,7 +74,11 @@ func (c *Cron) Process(ctx context.Context, args selina.ProcessArgs) error {
 		case <-tick:
 			msg := selina.GetBuffer()
 			msg.Write(c.opts.Message)
-			args.Output <- msg
+			if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+				return err
+			}
+			now = clock.Now()
+			tick = clock.After(sched.Next(now).Sub(now))
 		}
 	}
 }
@@ -88,3 +87,12 @@ func (c *Cron) Process(ctx context.Context, args selina.ProcessArgs) error {
 func NewCron(opts CronOptions) *Cron {
 	return &Cron{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (c *Cron) Describe() (string, map[string]interface{}, error) {
+	args := map[string]interface{}{"spec": c.opts.Spec}
+	if len(c.opts.Message) > 0 {
+		args["message"] = string(c.opts.Message)
+	}
+	return "cron", args, nil
+}
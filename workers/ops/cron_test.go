@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/selinatest"
 	"golang.org/x/net/context"
 
 	"github.com/licaonfee/selina/workers"
@@ -39,7 +40,7 @@ func TestCronProcess(t *testing.T) {
 		name    string
 		opts    ops.CronOptions
 		want    []string
-		runFor  time.Duration
+		ticks   int
 		wantErr error
 	}{
 		{
@@ -52,46 +53,58 @@ func TestCronProcess(t *testing.T) {
 			name:    "Tick message",
 			opts:    ops.CronOptions{Spec: "@every 1s", Message: []byte("foo")},
 			want:    []string{"foo"},
-			runFor:  time.Second,
+			ticks:   1,
 			wantErr: nil,
 		},
 		{
 			name:    "Tick nil",
 			opts:    ops.CronOptions{Spec: "@every 1s"},
 			want:    []string{""},
-			runFor:  time.Second,
+			ticks:   1,
+			wantErr: nil,
+		},
+		{
+			name:    "Many ticks",
+			opts:    ops.CronOptions{Spec: "*/10 * * * * *", Message: []byte("bar")},
+			want:    []string{"bar", "bar", "bar"},
+			ticks:   3,
 			wantErr: nil,
 		},
 		{
 			name:    "Bad spec",
 			opts:    ops.CronOptions{Spec: "@eberi 1z"},
 			want:    []string{},
-			runFor:  time.Second,
 			wantErr: ops.ErrBadCronSpec,
 		},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			clock := selinatest.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+			tt.opts.Clock = clock
 			c := ops.NewCron(tt.opts)
 			input := make(chan *bytes.Buffer)
 			output := make(chan *bytes.Buffer, len(tt.want))
 			args := selina.ProcessArgs{Input: input, Output: output}
-			go func(wait time.Duration) {
-				time.Sleep(wait)
-				close(input)
-			}(tt.runFor)
-			if err := c.Process(context.Background(), args); !errors.Is(err, tt.wantErr) {
+			errC := make(chan error, 1)
+			go func() {
+				errC <- c.Process(context.Background(), args)
+			}()
+			got := []string{}
+			for i := 0; i < tt.ticks; i++ {
+				clock.BlockUntil(1)
+				clock.Advance(time.Second * 10)
+				got = append(got, (<-output).String())
+			}
+			close(input)
+			if err := <-errC; !errors.Is(err, tt.wantErr) {
 				t.Fatalf("Process() err = %v, wantErr %v", err, tt.wantErr)
 			}
-			got := []string{}
 			for _, b := range selina.ChannelAsSlice(output) {
 				got = append(got, b.String())
 			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("Process() got = %#v , want = %#v", got, tt.want)
 			}
-
 		})
 	}
 }
@@ -1,7 +1,9 @@
 package ops
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"time"
 
 	"github.com/licaonfee/selina"
@@ -17,8 +19,20 @@ type TimeSerieOptions struct {
 	Step        time.Duration
 	Generator   func(time.Time) float64
 	WriteFormat selina.Marshaler
+	// RealTime when true every point is emitted when Clock reach its time
+	// otherwise all points are emitted as fast as posible
+	RealTime bool
+	// Clock time source, default selina.SystemClock
+	// when Stop is zero Clock.Now() is used
+	Clock selina.Clock
+	// Handler is called on WriteFormat errors before node ErrorPolicy
+	Handler selina.ErrorHandler
+	// Watermarks when true a watermark with the time of every point is emitted after it
+	Watermarks bool
 }
 
+var errInputClosed = errors.New("input closed")
+
 // TimeSerie generate time and values in a given time range
 type TimeSerie struct {
 	opts TimeSerieOptions
@@ -27,28 +41,57 @@ type TimeSerie struct {
 // Process generate timeseries and put it out in a channel
 func (t *TimeSerie) Process(ctx context.Context, args selina.ProcessArgs) error {
 	defer close(args.Output)
-	ts := tserie.NewTimeIterator(t.opts.Start, t.opts.Stop, t.opts.Step, t.opts.Generator)
+	clock := t.opts.Clock
+	if clock == nil {
+		clock = selina.SystemClock
+	}
+	stop := t.opts.Stop
+	if stop.IsZero() {
+		stop = clock.Now()
+	}
+	ts := tserie.NewTimeIterator(t.opts.Start, stop, t.opts.Step, t.opts.Generator)
 	if t.opts.WriteFormat == nil {
 		t.opts.WriteFormat = selina.DefaultMarshaler
 	}
 	for {
 		select {
-		case _, ok := <-args.Input:
+		case msg, ok := <-args.Input:
 			if !ok {
 				return nil
 			}
+			selina.FreeBuffer(msg)
 		default:
 			if !ts.Next() {
 				return nil
 			}
-			b, err := t.opts.WriteFormat(ts.Item())
+			if t.opts.RealTime {
+				err := waitFor(ctx, clock, ts.Item().Time, args.Input)
+				switch {
+				case errors.Is(err, errInputClosed):
+					return nil
+				case err != nil:
+					return err
+				}
+			}
+			var b []byte
+			err := selina.HandleMessage(ctx, nil, t.opts.Handler, func() (err error) {
+				b, err = t.opts.WriteFormat(ts.Item())
+				return err
+			})
 			if err != nil {
 				return err
 			}
-			buff := selina.GetBuffer()
-			buff.Write(b)
-			if err := selina.SendContext(ctx, buff, args.Output); err != nil {
-				return err
+			if b != nil {
+				buff := selina.GetBuffer()
+				buff.Write(b)
+				if err := selina.SendContext(ctx, buff, args.Output); err != nil {
+					return err
+				}
+			}
+			if t.opts.Watermarks {
+				if err := selina.EmitWatermark(ctx, args.Output, ts.Item().Time); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -58,3 +101,26 @@ func (t *TimeSerie) Process(ctx context.Context, args selina.ProcessArgs) error
 func NewTimeSerie(opts TimeSerieOptions) *TimeSerie {
 	return &TimeSerie{opts: opts}
 }
+
+// waitFor block until clock reach t, it returns errInputClosed if input
+// is closed meanwhile
+func waitFor(ctx context.Context, clock selina.Clock, t time.Time, input <-chan *bytes.Buffer) error {
+	now := clock.Now()
+	if !t.After(now) {
+		return nil
+	}
+	tick := clock.After(t.Sub(now))
+	for {
+		select {
+		case <-tick:
+			return nil
+		case msg, ok := <-input:
+			if !ok {
+				return errInputClosed
+			}
+			selina.FreeBuffer(msg)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/selinatest"
 	"github.com/licaonfee/selina/workers"
 	"github.com/licaonfee/selina/workers/ops"
 )
@@ -101,3 +102,82 @@ func TestTimeSerieProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeSerieProcessRealTime(t *testing.T) {
+	start := time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := selinatest.NewFakeClock(start)
+	ts := ops.NewTimeSerie(ops.TimeSerieOptions{
+		Start:     start,
+		Stop:      start.Add(time.Minute * 2),
+		Step:      time.Minute,
+		Generator: func(t time.Time) float64 { return 1.0 },
+		RealTime:  true,
+		Clock:     clock,
+	})
+	input := make(chan *bytes.Buffer)
+	output := make(chan *bytes.Buffer, 3)
+	args := selina.ProcessArgs{Input: input, Output: output}
+	errC := make(chan error, 1)
+	go func() {
+		errC <- ts.Process(context.Background(), args)
+	}()
+	// first point is emitted immediately
+	<-output
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		if len(output) != 0 {
+			t.Fatalf("Process() emit points before clock reach them")
+		}
+		clock.Advance(time.Minute)
+		<-output
+	}
+	if err := <-errC; err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+}
+
+func TestTimeSerieProcessDefaultStop(t *testing.T) {
+	start := time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+	clock := selinatest.NewFakeClock(start.Add(time.Minute))
+	ts := ops.NewTimeSerie(ops.TimeSerieOptions{
+		Start:     start,
+		Step:      time.Minute,
+		Generator: func(t time.Time) float64 { return 1.0 },
+		Clock:     clock,
+	})
+	output := make(chan *bytes.Buffer, 3)
+	args := selina.ProcessArgs{Input: make(chan *bytes.Buffer), Output: output}
+	if err := ts.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	if got := len(selina.ChannelAsSlice(output)); got != 2 {
+		t.Fatalf("Process() got %d points, want 2", got)
+	}
+}
+
+func TestTimeSerieProcessWatermarks(t *testing.T) {
+	start := time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+	ts := ops.NewTimeSerie(ops.TimeSerieOptions{
+		Start:      start,
+		Stop:       start.Add(time.Minute),
+		Step:       time.Minute,
+		Generator:  func(t time.Time) float64 { return 1.0 },
+		Watermarks: true,
+	})
+	output := make(chan *bytes.Buffer, 4)
+	if err := ts.Process(context.Background(), selina.ProcessArgs{Input: make(chan *bytes.Buffer), Output: output}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	var marks []time.Time
+	points := 0
+	for _, b := range selina.ChannelAsSlice(output) {
+		if wm, ok := selina.IsWatermark(b); ok {
+			marks = append(marks, wm)
+			continue
+		}
+		points++
+	}
+	if points != 2 || len(marks) != 2 || !marks[0].Equal(start) || !marks[1].Equal(start.Add(time.Minute)) {
+		t.Fatalf("Process() points = %d, watermarks = %v", points, marks)
+	}
+}
@@ -59,3 +59,8 @@ func (r *Random) Process(ctx context.Context, args selina.ProcessArgs) error {
 func NewRandom(opts Options) *Random {
 	return &Random{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (r *Random) Describe() (string, map[string]interface{}, error) {
+	return "random", map[string]interface{}{"len": r.opts.Len}, nil
+}
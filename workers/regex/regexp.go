@@ -41,8 +41,15 @@ func (r *Filter) Process(ctx context.Context, args selina.ProcessArgs) error {
 		case msg, ok := <-args.Input:
 			if ok {
 				if re.Match(msg.Bytes()) {
-					args.Output <- msg
+					if err := selina.SendContext(ctx, msg, args.Output); err != nil {
+						selina.FreeBuffer(msg)
+						return err
+					}
+					continue
 				}
+				// filtered messages are processed, so they are acknowledged
+				selina.Ack(msg, nil)
+				selina.FreeBuffer(msg)
 			} else {
 				return nil
 			}
@@ -56,3 +63,8 @@ func (r *Filter) Process(ctx context.Context, args selina.ProcessArgs) error {
 func NewFilter(opts FilterOptions) *Filter {
 	return &Filter{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (r *Filter) Describe() (string, map[string]interface{}, error) {
+	return "regex", map[string]interface{}{"pattern": r.opts.Pattern}, nil
+}
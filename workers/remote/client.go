@@ -2,6 +2,8 @@ package remote
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/licaonfee/selina"
 	"google.golang.org/grpc"
@@ -13,6 +15,8 @@ var _ selina.Worker = (*Client)(nil)
 // ClientOptions customize client
 type ClientOptions struct {
 	Address string
+	// Handler is called on Send errors before node ErrorPolicy
+	Handler selina.ErrorHandler
 }
 
 // Client connect to a remote grpc endpoint
@@ -29,6 +33,7 @@ func (c *Client) Process(ctx context.Context, args selina.ProcessArgs) error {
 	}
 	//TODO: handle error
 	defer conn.Close()
+	selina.LoggerFromContext(ctx).Debug("remote client connected", "address", c.opts.Address)
 	wc := NewWorkerClient(conn)
 	for {
 		select {
@@ -38,9 +43,25 @@ func (c *Client) Process(ctx context.Context, args selina.ProcessArgs) error {
 			}
 			data := make([]byte, len(msg.Bytes()))
 			copy(data, msg.Bytes())
+			done := selina.Detach(msg)
 			selina.FreeBuffer(msg)
 			m := Message{Data: data}
-			_, err := wc.Send(ctx, &m)
+			var sendErr error
+			err := selina.HandleMessage(ctx, data, c.opts.Handler, func() error {
+				resp, err := wc.Send(ctx, &m)
+				if err == nil && resp.GetMessage() != "" {
+					// server nacked message (see ServerOptions.WaitAck)
+					err = errors.New(resp.GetMessage())
+				}
+				sendErr = err
+				return err
+			})
+			// skipped or routed messages are nacked with last send error
+			done(sendErr)
+			if ctx.Err() != nil {
+				// grpc status of a canceled call does not wrap context error
+				return ctx.Err()
+			}
 			if err != nil {
 				return err
 			}
@@ -54,3 +75,11 @@ func (c *Client) Process(ctx context.Context, args selina.ProcessArgs) error {
 func NewClient(opts ClientOptions) *Client {
 	return &Client{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (c *Client) Describe() (string, map[string]interface{}, error) {
+	if c.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	return "remote", map[string]interface{}{"mode": "client", "address": c.opts.Address}, nil
+}
@@ -4,11 +4,13 @@ import (
 	"bytes"
 	context "context"
 	"errors"
+	"net"
 	"testing"
 
 	"github.com/licaonfee/selina"
 	"github.com/licaonfee/selina/workers"
 	"github.com/licaonfee/selina/workers/remote"
+	"google.golang.org/grpc"
 )
 
 func TestClientProcess(t *testing.T) {
@@ -34,8 +36,25 @@ func TestClientProcess(t *testing.T) {
 	}
 }
 
+// ackServer accept every message
+type ackServer struct {
+	remote.UnimplementedWorkerServer
+}
+
+func (ackServer) Send(context.Context, *remote.Message) (*remote.Error, error) {
+	return &remote.Error{}, nil
+}
+
 func TestClientProcessCancelation(t *testing.T) {
-	r := remote.NewClient(remote.ClientOptions{Address: "localhost:7777"})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	remote.RegisterWorkerServer(srv, ackServer{})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+	r := remote.NewClient(remote.ClientOptions{Address: lis.Addr().String()})
 	if err := workers.ATProcessCancel(r); err != nil {
 		t.Fatal(err)
 	}
@@ -23,30 +23,54 @@ type ServerOptions struct {
 	Network    string
 	Address    string
 	BufferSize int
+	// WaitAck when true Send returns after message is acknowledged by
+	// all sinks (see selina.OnAck), a nack is returned to the client as an Error
+	WaitAck bool
+}
+
+// received is a message and the channel that receive its ack if any
+type received struct {
+	data []byte
+	done chan error
 }
 
 // Server receive data from a remote endpoint
 type Server struct {
 	UnimplementedWorkerServer
 	opts  ServerOptions
-	dataC chan []byte
+	dataC chan received
 }
 
 // Send implements grpc service
 func (s *Server) Send(ctx context.Context, msg *Message) (*Error, error) {
+	r := received{data: msg.Data}
+	if s.opts.WaitAck {
+		r.done = make(chan error, 1)
+	}
+	select {
+	case <-ctx.Done():
+		return &Error{Message: ctx.Err().Error()}, ctx.Err()
+	case s.dataC <- r:
+	}
+	if r.done == nil {
+		return &Error{}, nil
+	}
 	select {
 	case <-ctx.Done():
 		return &Error{Message: ctx.Err().Error()}, ctx.Err()
-	case s.dataC <- msg.Data:
+	case err := <-r.done:
+		if err != nil {
+			return &Error{Message: err.Error()}, nil
+		}
 		return &Error{}, nil
 	}
 }
 
 // Push put a []byte into process stream, return ErrDiscarded if
-// msg is not send immediately
+// msg is not send immediately, pushed messages are not tracked
 func (s *Server) Push(msg []byte) error {
 	select {
-	case s.dataC <- msg:
+	case s.dataC <- received{data: msg}:
 		return nil
 	default:
 		return ErrDiscarded
@@ -63,6 +87,7 @@ func (s *Server) Process(ctx context.Context, args selina.ProcessArgs) (errp err
 		return err
 	}
 
+	selina.LoggerFromContext(ctx).Info("remote server listening", "address", listener.Addr().String())
 	gserver := grpc.NewServer()
 	RegisterWorkerServer(gserver, s)
 	var cerr error
@@ -74,6 +99,10 @@ func (s *Server) Process(ctx context.Context, args selina.ProcessArgs) (errp err
 	}()
 	go func() {
 		cerr = gserver.Serve(listener)
+		// Process can finish before Serve is called
+		if errors.Is(cerr, grpc.ErrServerStopped) {
+			cerr = nil
+		}
 		wg.Done()
 	}()
 	defer gserver.GracefulStop()
@@ -84,9 +113,13 @@ func (s *Server) Process(ctx context.Context, args selina.ProcessArgs) (errp err
 				return nil
 			}
 			selina.FreeBuffer(x)
-		case data := <-s.dataC:
+		case r := <-s.dataC:
 			msg := selina.GetBuffer()
-			msg.Write(data)
+			msg.Write(r.data)
+			if r.done != nil {
+				done := r.done
+				selina.OnAck(msg, func(err error) { done <- err })
+			}
 			if err := selina.SendContext(ctx, msg, args.Output); err != nil {
 				return err
 			}
@@ -100,5 +133,17 @@ func (s *Server) Process(ctx context.Context, args selina.ProcessArgs) (errp err
 // NewServer create a new grpc server with given options
 func NewServer(opts ServerOptions) *Server {
 	return &Server{opts: opts,
-		dataC: make(chan []byte, opts.BufferSize)}
+		dataC: make(chan received, opts.BufferSize)}
+}
+
+// Describe implements selina.Describer interface
+func (s *Server) Describe() (string, map[string]interface{}, error) {
+	args := map[string]interface{}{"mode": "server", "address": s.opts.Network + "://" + s.opts.Address}
+	if s.opts.BufferSize > 0 {
+		args["buffer"] = s.opts.BufferSize
+	}
+	if s.opts.WaitAck {
+		args["wait_ack"] = true
+	}
+	return "remote", args, nil
 }
@@ -93,3 +93,36 @@ func TestServerProcessCloseOutput(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestServerSendWaitAck(t *testing.T) {
+	tests := []struct {
+		name string
+		ack  bool
+		want string
+	}{
+		{name: "ack", ack: true, want: ""},
+		{name: "nack", ack: false, want: selina.ErrNotAcked.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := remote.NewServer(remote.ServerOptions{Network: "tcp", Address: ":0", WaitAck: true})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			output := make(chan *bytes.Buffer)
+			go func() {
+				_ = srv.Process(ctx, selina.ProcessArgs{Output: output})
+			}()
+			go func() {
+				msg := <-output
+				if tt.ack {
+					selina.Ack(msg, nil)
+				}
+				selina.FreeBuffer(msg)
+			}()
+			resp, err := srv.Send(ctx, &remote.Message{Data: []byte("foo")})
+			if err != nil || resp.GetMessage() != tt.want {
+				t.Fatalf("Send() = %v, %v, want %s", resp, err, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/licaonfee/magiccol"
 	"github.com/licaonfee/selina"
@@ -25,6 +26,11 @@ type ReaderOptions struct {
 	Mapper *magiccol.Mapper
 	// WriteFormat default is json.Marshal
 	WriteFormat selina.Marshaler
+	// Handler is called on query errors before node ErrorPolicy
+	Handler selina.ErrorHandler
+	// WatermarkColumn when not empty a watermark is emitted every time
+	// this column reach a new maximum, see selina.EventTime for valid values
+	WatermarkColumn string
 }
 
 // Check if a combination of options is valid
@@ -79,14 +85,26 @@ func (s *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 	}
 	for {
 		select {
-		case _, ok := <-input:
+		case msg, ok := <-input:
 			if !ok {
 				return nil
 			}
-			rows, err := db.QueryContext(ctx, s.opts.Query)
+			var trigger []byte
+			if msg != nil {
+				trigger = msg.Bytes()
+			}
+			var rows *sql.Rows
+			err := selina.HandleMessage(ctx, trigger, s.opts.Handler, func() (err error) {
+				rows, err = db.QueryContext(ctx, s.opts.Query)
+				return err
+			})
+			selina.FreeBuffer(msg)
 			if err != nil {
 				return err
 			}
+			if rows == nil {
+				continue
+			}
 			if err := s.serializeRows(ctx, codec, rows, args.Output); err != nil {
 				return err
 			}
@@ -108,6 +126,7 @@ func (s *Reader) serializeRows(ctx context.Context, codec selina.Marshaler, rows
 	if err != nil {
 		return err
 	}
+	var last time.Time
 	for sc.Scan() {
 		sc.SetMap(obj)
 		msg, err := codec(obj)
@@ -119,6 +138,15 @@ func (s *Reader) serializeRows(ctx context.Context, codec selina.Marshaler, rows
 		if err := selina.SendContext(ctx, buff, out); err != nil {
 			return err
 		}
+		if s.opts.WatermarkColumn == "" {
+			continue
+		}
+		if t, ok := selina.EventTime(obj[s.opts.WatermarkColumn]); ok && t.After(last) {
+			last = t
+			if err := selina.EmitWatermark(ctx, out, t); err != nil {
+				return err
+			}
+		}
 	}
 	if sc.Err() != nil {
 		return sc.Err()
@@ -130,3 +158,18 @@ func (s *Reader) serializeRows(ctx context.Context, codec selina.Marshaler, rows
 func NewReader(opts ReaderOptions) *Reader {
 	return &Reader{opts: opts}
 }
+
+// Describe implements selina.Describer interface
+func (s *Reader) Describe() (string, map[string]interface{}, error) {
+	if s.opts.Mapper != nil || s.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Mapper or Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"driver": s.opts.Driver, "dsn": s.opts.ConnStr, "query": s.opts.Query}
+	if s.opts.WatermarkColumn != "" {
+		args["watermark_column"] = s.opts.WatermarkColumn
+	}
+	if err := selina.DescribeFormat(args, "write_format", s.opts.WriteFormat); err != nil {
+		return "", nil, err
+	}
+	return "sql_query", args, nil
+}
@@ -3,6 +3,7 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/licaonfee/selina"
@@ -10,6 +11,9 @@ import (
 
 var _ selina.Worker = (*Writer)(nil)
 
+// ErrEmptyRecord is returned for messages without columns, they can not be inserted
+var ErrEmptyRecord = errors.New("record without columns")
+
 // WriterOptions provide parameters to create a Writer
 type WriterOptions struct {
 	//Driver which driver should be used
@@ -23,6 +27,8 @@ type WriterOptions struct {
 	Builder QueryBuilder
 	//ReadFormat by default is  is json.Unmarshal
 	ReadFormat selina.Unmarshaler
+	//Handler is called on decode and insert errors before node ErrorPolicy
+	Handler selina.ErrorHandler
 }
 
 // Check if a combination of options is valid
@@ -68,17 +74,21 @@ func (s *Writer) Process(ctx context.Context, args selina.ProcessArgs) error {
 			if !ok {
 				return nil
 			}
-			cols, values, err := deserialize(codec, data.Bytes())
-			selina.FreeBuffer(data)
-			if err != nil {
-				return err
-			}
 			if s.opts.Builder == nil {
 				s.opts.Builder = &DefaultQueryBuilder{}
 			}
-
-			query := s.opts.Builder.Insert(s.opts.Table, cols)
-			_, err = conn.ExecContext(ctx, query, values...)
+			err := selina.HandleMessage(ctx, data.Bytes(), s.opts.Handler, func() error {
+				cols, values, err := deserialize(codec, data.Bytes())
+				if err != nil {
+					return err
+				}
+				query := s.opts.Builder.Insert(s.opts.Table, cols)
+				if _, err = conn.ExecContext(ctx, query, values...); err == nil {
+					selina.Ack(data, nil)
+				}
+				return err
+			})
+			selina.FreeBuffer(data)
 			if err != nil {
 				return err
 			}
@@ -98,6 +108,9 @@ func deserialize(codec selina.Unmarshaler, data []byte) (cols []string, values [
 	if err := codec(data, &obj); err != nil {
 		return nil, nil, err
 	}
+	if len(obj) == 0 {
+		return nil, nil, ErrEmptyRecord
+	}
 	cols = make([]string, 0, len(obj))
 	values = make([]interface{}, 0, len(obj))
 	for name, val := range obj {
@@ -106,3 +119,15 @@ func deserialize(codec selina.Unmarshaler, data []byte) (cols []string, values [
 	}
 	return cols, values, nil
 }
+
+// Describe implements selina.Describer interface
+func (s *Writer) Describe() (string, map[string]interface{}, error) {
+	if s.opts.Builder != nil || s.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Builder or Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"driver": s.opts.Driver, "dsn": s.opts.ConnStr, "table": s.opts.Table}
+	if err := selina.DescribeFormat(args, "read_format", s.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	return "sql_insert", args, nil
+}
@@ -54,6 +54,12 @@ func TestWriterProcess(t *testing.T) {
 			in:      []string{},
 			wantErr: true,
 		},
+		{
+			name:    "Empty record",
+			opts:    sql.WriterOptions{Driver: ramsqlDriver, ConnStr: "w_empty_record", Table: "members"},
+			in:      []string{`{}`},
+			wantErr: true,
+		},
 		{
 			name:    "Empty table",
 			opts:    sql.WriterOptions{Driver: ramsqlDriver, ConnStr: "w_empty_table", Table: ""},
@@ -128,7 +134,7 @@ func TestWriterProcessCancel(t *testing.T) {
 		ConnStr: dbname,
 		Table:   "members",
 	})
-	if err := workers.ATProcessCancel(s); err != nil {
+	if err := workers.ATProcessCancel(s, `{"id":2,"name":"alice","mood":"calm"}`, `{"id":3,"name":"bob","mood":"calm"}`); err != nil {
 		t.Fatal(err)
 	}
 }
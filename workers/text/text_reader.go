@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"time"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/compress"
 )
 
 var _ selina.Worker = (*Reader)(nil)
@@ -30,14 +34,29 @@ type ReaderOptions struct {
 	ReadFormat selina.Unmarshaler
 	// WriteFormat by default is json.Marshal
 	WriteFormat selina.Marshaler
+	// Compression decompress Reader, compress.Auto detect it from magic bytes
+	// default is no compression
+	Compression compress.Format
+	// Handler is called on format errors before node ErrorPolicy
+	Handler selina.ErrorHandler
+	// WatermarkField when not empty a watermark is emitted every time this field
+	// of records reach a new maximum, it requires a ReadFormat that decodes
+	// into an object, see selina.EventTime for valid values
+	WatermarkField string
 }
 
+// ErrWatermarkFormat is returned when WatermarkField is used without ReadFormat
+var ErrWatermarkFormat = errors.New("watermark field requires a read format")
+
 // Check if a combination of options is valid
 func (o ReaderOptions) Check() error {
 	if o.Reader == nil {
 		return ErrNilReader
 	}
-	return nil
+	if o.WatermarkField != "" && o.ReadFormat == nil {
+		return ErrWatermarkFormat
+	}
+	return o.Compression.Check()
 }
 
 // Reader a worker that read data from an io.Reader
@@ -67,7 +86,12 @@ func (t *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 	if err := t.opts.Check(); err != nil {
 		return err
 	}
-	sc := bufio.NewScanner(t.opts.Reader)
+	rd, err := compress.NewReader(t.opts.Reader, t.opts.Compression)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	sc := bufio.NewScanner(rd)
 	if t.opts.SplitFunc != nil {
 		sc.Split(t.opts.SplitFunc)
 	}
@@ -75,6 +99,7 @@ func (t *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 	if t.opts.WriteFormat != nil {
 		wf = t.opts.WriteFormat
 	}
+	var last time.Time
 	for sc.Scan() {
 		select {
 		case _, ok := <-args.Input:
@@ -85,24 +110,42 @@ func (t *Reader) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 			return ctx.Err()
 		default:
 			msg := []byte(sc.Text())
+			var event time.Time
 			if t.opts.ReadFormat != nil {
-				data := new(interface{})
-				if err := t.opts.ReadFormat(msg, data); err != nil {
+				line := msg
+				msg = nil
+				err := selina.HandleMessage(ctx, line, t.opts.Handler, func() (err error) {
+					data := new(interface{})
+					if err := t.opts.ReadFormat(line, data); err != nil {
+						return err
+					}
+					if obj, ok := (*data).(map[string]interface{}); ok && t.opts.WatermarkField != "" {
+						event, _ = selina.EventTime(obj[t.opts.WatermarkField])
+					}
+					msg, err = wf(data)
 					return err
-				}
-				msg, err = wf(data)
+				})
 				if err != nil {
 					return err
 				}
+				if msg == nil {
+					continue
+				}
 			}
 			b := selina.GetBuffer()
 			b.Write(msg)
 			if err := selina.SendContext(ctx, b, args.Output); err != nil {
 				return err
 			}
+			if event.After(last) {
+				last = event
+				if err := selina.EmitWatermark(ctx, args.Output, event); err != nil {
+					return err
+				}
+			}
 		}
 	}
-	return nil
+	return sc.Err()
 }
 
 // NewReader create a new Reader with given options
@@ -110,3 +153,37 @@ func NewReader(opts ReaderOptions) *Reader {
 	t := Reader{opts: opts}
 	return &t
 }
+
+// Describe implements selina.Describer interface, Reader must be a file
+func (t *Reader) Describe() (string, map[string]interface{}, error) {
+	f, ok := t.opts.Reader.(interface{ Name() string })
+	if !ok {
+		return "", nil, fmt.Errorf("%w: Reader is not a file", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"filename": f.Name()}
+	split := reflect.ValueOf(t.opts.SplitFunc)
+	switch {
+	case t.opts.SplitFunc == nil:
+	case split.Pointer() == reflect.ValueOf(bufio.ScanLines).Pointer():
+	case split.Pointer() == reflect.ValueOf(bufio.ScanBytes).Pointer():
+		args["split"] = "byte"
+	case split.Pointer() == reflect.ValueOf(bufio.ScanRunes).Pointer():
+		args["split"] = "char"
+	default:
+		return "", nil, fmt.Errorf("%w: custom SplitFunc", selina.ErrNotDescribable)
+	}
+	args["compression"] = string(compress.None)
+	if t.opts.Compression != "" {
+		args["compression"] = string(t.opts.Compression)
+	}
+	if t.opts.WatermarkField != "" {
+		args["watermark_field"] = t.opts.WatermarkField
+	}
+	if err := selina.DescribeFormat(args, "read_format", t.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	if err := selina.DescribeFormat(args, "write_format", t.opts.WriteFormat); err != nil {
+		return "", nil, err
+	}
+	return "read_file", args, nil
+}
@@ -3,6 +3,7 @@ package text_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -10,10 +11,12 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/licaonfee/selina"
 
 	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/compress"
 	"github.com/licaonfee/selina/workers/text"
 )
 
@@ -122,8 +125,7 @@ func TestReaderProcess(t *testing.T) {
 }
 
 func TestReaderProcessCancel(t *testing.T) {
-	fileContents := []string{"fooo", "bar"}
-	rd := strings.NewReader(strings.Join(fileContents, "\n"))
+	rd := strings.NewReader(strings.Repeat("fooo\n", 1000))
 	tr := text.NewReader(text.ReaderOptions{Reader: rd})
 	if err := workers.ATProcessCancel(tr); err != nil {
 		t.Fatal(err)
@@ -159,3 +161,69 @@ func TestReaderProcessNilReader(t *testing.T) {
 		t.Fatalf("Process() err = %T(%v)", err, err)
 	}
 }
+
+func TestReaderProcessCompressed(t *testing.T) {
+	data := &bytes.Buffer{}
+	gz := gzip.NewWriter(data)
+	_, _ = gz.Write([]byte("first line\nsecond line\n"))
+	_ = gz.Close()
+	tests := []struct {
+		name string
+		opts text.ReaderOptions
+	}{
+		{name: "explicit gzip", opts: text.ReaderOptions{Compression: compress.Gzip}},
+		{name: "auto detect", opts: text.ReaderOptions{Compression: compress.Auto}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Reader = bytes.NewReader(data.Bytes())
+			r := text.NewReader(tt.opts)
+			input := make(chan *bytes.Buffer)
+			output := make(chan *bytes.Buffer, 2)
+			args := selina.ProcessArgs{Input: input, Output: output}
+			if err := r.Process(context.Background(), args); err != nil {
+				t.Fatalf("Process() err = %v", err)
+			}
+			got := []string{}
+			for _, b := range selina.ChannelAsSlice(output) {
+				got = append(got, b.String())
+			}
+			want := []string{"first line", "second line"}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Process() got = %v , want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReaderProcessWatermark(t *testing.T) {
+	data := `{"ts":"2022-01-01T01:00:00Z"}
+{"ts":"2022-01-01T00:59:00Z"}
+{"ts":"2022-01-01T01:01:00Z"}
+`
+	r := text.NewReader(text.ReaderOptions{Reader: strings.NewReader(data), ReadFormat: json.Unmarshal, WatermarkField: "ts"})
+	output := make(chan *bytes.Buffer, 5)
+	if err := r.Process(context.Background(), selina.ProcessArgs{Output: output}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	var got []string
+	for _, b := range selina.ChannelAsSlice(output) {
+		if wm, ok := selina.IsWatermark(b); ok {
+			got = append(got, wm.UTC().Format(time.RFC3339))
+			continue
+		}
+		got = append(got, "record")
+	}
+	// a record older than last watermark does not emit a new one
+	want := []string{"record", "2022-01-01T01:00:00Z", "record", "record", "2022-01-01T01:01:00Z"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Process() got = %v, want %v", got, want)
+	}
+}
+
+func TestReaderWatermarkRequiresFormat(t *testing.T) {
+	opts := text.ReaderOptions{Reader: strings.NewReader(""), WatermarkField: "ts"}
+	if err := opts.Check(); !errors.Is(err, text.ErrWatermarkFormat) {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
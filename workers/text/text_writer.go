@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/compress"
 )
 
 var _ selina.Worker = (*Writer)(nil)
@@ -20,7 +22,15 @@ type WriterOptions struct {
 	AutoClose   bool
 	SkipNewLine bool
 	BufferSize  int
-	Codec       selina.Marshaler
+	// ReadFormat if not nil messages are decoded before Codec is applied
+	ReadFormat selina.Unmarshaler
+	// Codec encode messages before write them, default nil write raw bytes
+	Codec selina.Marshaler
+	// Compression compress data before write it into Writer
+	// default is no compression
+	Compression compress.Format
+	// Handler is called on format errors before node ErrorPolicy
+	Handler selina.ErrorHandler
 }
 
 // Check if a combination of options is valid
@@ -28,7 +38,7 @@ func (o WriterOptions) Check() error {
 	if o.Writer == nil {
 		return ErrNilWriter
 	}
-	return nil
+	return o.Compression.CheckWrite()
 }
 
 // Writer a Worker that write data to a given io.Writer in text format
@@ -61,11 +71,35 @@ func (t *Writer) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 	if err := t.opts.Check(); err != nil {
 		return err
 	}
-	w := bufio.NewWriterSize(t.opts.Writer, t.opts.BufferSize)
+	cw, err := compress.NewWriter(t.opts.Writer, t.opts.Compression)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(cw, t.opts.BufferSize)
+	// acks of tracked messages are delayed until data is flushed
+	var pending []func(error)
+	ack := func(err error) {
+		for _, done := range pending {
+			done(err)
+		}
+		pending = pending[:0]
+	}
+	flush := func() error {
+		err := w.Flush()
+		if f, ok := cw.(interface{ Flush() error }); ok && err == nil {
+			err = f.Flush()
+		}
+		ack(err)
+		return err
+	}
 	defer func() {
 		if errFlush := w.Flush(); errFlush != nil {
 			err = errFlush
 		}
+		if errClose := cw.Close(); err == nil && errClose != nil {
+			err = errClose
+		}
+		ack(err)
 	}()
 	newLine := []byte("\n")
 	for {
@@ -74,17 +108,31 @@ func (t *Writer) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 			if !ok {
 				return nil
 			}
-			var data []byte
+			data := msg.Bytes()
 			if t.opts.Codec != nil {
-				data, err = t.opts.Codec(msg.Bytes())
-				if err != nil {
+				data = nil
+				err = selina.HandleMessage(ctx, msg.Bytes(), t.opts.Handler, func() (err error) {
+					var value interface{} = msg.Bytes()
+					if t.opts.ReadFormat != nil {
+						if err := t.opts.ReadFormat(msg.Bytes(), &value); err != nil {
+							return err
+						}
+					}
+					data, err = t.opts.Codec(value)
 					return err
+				})
+				if err != nil || data == nil {
+					selina.FreeBuffer(msg)
+					if err != nil {
+						return err
+					}
+					continue
 				}
-				//selina.FreeBytes(&msg)
-				msg.Reset()
-				msg.Write(data)
 			}
-			_, err = io.Copy(w, msg)
+			if selina.IsTracked(msg) {
+				pending = append(pending, selina.Detach(msg))
+			}
+			_, err = w.Write(data)
 			selina.FreeBuffer(msg)
 			if err != nil {
 				return
@@ -95,6 +143,11 @@ func (t *Writer) Process(ctx context.Context, args selina.ProcessArgs) (err erro
 					return
 				}
 			}
+			if len(pending) > 0 && len(args.Input) == 0 {
+				if err = flush(); err != nil {
+					return
+				}
+			}
 
 		case <-ctx.Done():
 			return ctx.Err()
@@ -107,3 +160,28 @@ func NewWriter(opts WriterOptions) *Writer {
 	w := &Writer{opts: opts}
 	return w
 }
+
+// Describe implements selina.Describer interface, Writer must be a file
+func (t *Writer) Describe() (string, map[string]interface{}, error) {
+	f, ok := t.opts.Writer.(interface{ Name() string })
+	if !ok {
+		return "", nil, fmt.Errorf("%w: Writer is not a file", selina.ErrNotDescribable)
+	}
+	if t.opts.SkipNewLine {
+		return "", nil, fmt.Errorf("%w: SkipNewLine", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"filename": f.Name()}
+	if t.opts.BufferSize > 0 {
+		args["buffer"] = t.opts.BufferSize
+	}
+	if t.opts.Compression != "" {
+		args["compression"] = string(t.opts.Compression)
+	}
+	if err := selina.DescribeFormat(args, "read_format", t.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	if err := selina.DescribeFormat(args, "write_format", t.opts.Codec); err != nil {
+		return "", nil, err
+	}
+	return "write_file", args, nil
+}
@@ -3,12 +3,19 @@ package text_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
 	"reflect"
 	"testing"
 
 	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/compress"
 	"github.com/licaonfee/selina/workers/text"
+	"gopkg.in/yaml.v2"
 
 	"github.com/licaonfee/selina"
 )
@@ -71,3 +78,76 @@ func TestWriterProcessCancel(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWriterProcessCompression(t *testing.T) {
+	fileContents := []string{"first line", "second line"}
+	w := &bytes.Buffer{}
+	tw := text.NewWriter(text.WriterOptions{Writer: w, Compression: compress.Gzip})
+	in := selina.SliceAsChannelOfBuffer(fileContents, true)
+	out := make(chan *bytes.Buffer)
+	args := selina.ProcessArgs{Input: in, Output: out}
+	if err := tw.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	rd, err := gzip.NewReader(w)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() err = %v", err)
+	}
+	got, _ := io.ReadAll(rd)
+	if want := "first line\nsecond line\n"; string(got) != want {
+		t.Fatalf("Process() got = %q , want = %q", got, want)
+	}
+}
+
+func TestWriterProcessReadFormat(t *testing.T) {
+	w := &bytes.Buffer{}
+	tw := text.NewWriter(text.WriterOptions{Writer: w, ReadFormat: json.Unmarshal, Codec: yaml.Marshal})
+	in := selina.SliceAsChannelOfBuffer([]string{`{"name":"selina"}`}, true)
+	out := make(chan *bytes.Buffer)
+	args := selina.ProcessArgs{Input: in, Output: out}
+	if err := tw.Process(context.Background(), args); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	if want := "name: selina\n\n"; w.String() != want {
+		t.Fatalf("Process() got = %q , want = %q", w.String(), want)
+	}
+}
+
+func TestWriterDescribe(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := text.NewWriter(text.WriterOptions{Writer: f, Codec: json.Marshal, Compression: compress.Gzip})
+	typ, args, err := w.Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	want := map[string]interface{}{"filename": f.Name(), "write_format": "json", "compression": "gzip"}
+	if typ != "write_file" || !reflect.DeepEqual(args, want) {
+		t.Fatalf("Describe() got = %s %v, want %v", typ, args, want)
+	}
+	if _, _, err := text.NewWriter(text.WriterOptions{Writer: &bytes.Buffer{}}).Describe(); !errors.Is(err, selina.ErrNotDescribable) {
+		t.Fatalf("Describe() err = %v", err)
+	}
+}
+
+func TestWriterProcessAck(t *testing.T) {
+	input := make(chan *bytes.Buffer, 1)
+	output := make(chan *bytes.Buffer)
+	msg := selina.GetBuffer()
+	msg.WriteString("foo")
+	acked := make(chan error, 1)
+	selina.OnAck(msg, func(err error) { acked <- err })
+	input <- msg
+	close(input)
+	out := &bytes.Buffer{}
+	w := text.NewWriter(text.WriterOptions{Writer: out, BufferSize: 1024})
+	if err := w.Process(context.Background(), selina.ProcessArgs{Input: input, Output: output}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	if err := <-acked; err != nil || out.String() != "foo\n" {
+		t.Fatalf("Process() ack = %v, out = %q", err, out.String())
+	}
+}
@@ -0,0 +1,354 @@
+// Package tsv workers to read and write tab separated values, tabs,
+// newlines and backslashes in values are escaped as \t, \n, \r and \\
+// so every record is a single line without quotes
+package tsv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers/csv"
+)
+
+// ErrFieldCount is returned for rows with a different number of fields than header
+var ErrFieldCount = errors.New("wrong number of fields")
+
+// ErrNoHeader is returned by DecoderOptions.Check without Header nor HeaderFromFirst
+var ErrNoHeader = errors.New("header is required")
+
+var (
+	escaper   = strings.NewReplacer("\\", `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	unescaper = strings.NewReplacer(`\\`, "\\", `\t`, "\t", `\n`, "\n", `\r`, "\r")
+)
+
+// escape returns a copy of row with escaped fields
+func escape(row []string) []string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		cells[i] = escaper.Replace(cell)
+	}
+	return cells
+}
+
+var _ selina.Worker = (*Encoder)(nil)
+
+// EncoderOptions configure tsv encoding
+type EncoderOptions struct {
+	// Header acts as a filter, if a field is not in header is skipped,
+	// default is the sorted fields of first record
+	Header []string
+	// NoHeader do not write header
+	NoHeader   bool
+	ReadFormat selina.Unmarshaler
+	Handler    selina.ErrorHandler
+	// Null text of null values, default is an empty field like missing fields
+	Null string
+}
+
+// Check if a combination of options is valid
+func (o EncoderOptions) Check() error {
+	if err := checkNull(o.Null); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(o.Header))
+	for _, name := range o.Header {
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("duplicated field %s", name)
+		}
+		names[name] = struct{}{}
+	}
+	return nil
+}
+
+// checkNull rejects null texts that break a line into fields or lines
+func checkNull(null string) error {
+	if strings.ContainsAny(null, "\t\n\r") {
+		return fmt.Errorf("invalid null text %q", null)
+	}
+	return nil
+}
+
+// Encoder transform messages into tsv lines
+type Encoder struct {
+	opts EncoderOptions
+}
+
+// NewEncoder returns a new Encoder with given options
+func NewEncoder(opts EncoderOptions) *Encoder {
+	return &Encoder{opts: opts}
+}
+
+// Process implements selina.Worker interface
+func (e *Encoder) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	if args.Input == nil {
+		return selina.ErrNilUpstream
+	}
+	if err := e.opts.Check(); err != nil {
+		return err
+	}
+	headerWriten := e.opts.NoHeader
+	fields := e.opts.Header
+	rf := selina.DefaultUnmarshaler
+	if e.opts.ReadFormat != nil {
+		rf = e.opts.ReadFormat
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			var row []string
+			err := selina.HandleMessage(ctx, msg.Bytes(), e.opts.Handler, func() error {
+				data := make(map[string]interface{})
+				if err := rf(msg.Bytes(), &data); err != nil {
+					return err
+				}
+				if len(fields) == 0 {
+					fields = csv.Fields(data)
+				}
+				var err error
+				row, err = e.row(fields, data)
+				return err
+			})
+			if err != nil || row == nil {
+				selina.FreeBuffer(msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if !headerWriten {
+				b := selina.GetBuffer()
+				b.WriteString(strings.Join(escape(fields), "\t"))
+				if err := selina.SendContext(ctx, b, args.Output); err != nil {
+					selina.FreeBuffer(msg)
+					return err
+				}
+				headerWriten = true
+			}
+			b := selina.GetBuffer()
+			b.WriteString(strings.Join(row, "\t"))
+			selina.Forward(msg, b)
+			selina.FreeBuffer(msg)
+			if err := selina.SendContext(ctx, b, args.Output); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// row returns escaped fields of data, Null is not escaped
+func (e *Encoder) row(fields []string, data map[string]interface{}) ([]string, error) {
+	var f csv.Formatter
+	res := make([]string, len(fields))
+	for i, name := range fields {
+		value, ok := data[name]
+		switch {
+		case !ok:
+			continue
+		case value == nil:
+			res[i] = e.opts.Null
+			continue
+		}
+		cell, err := f.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		res[i] = escaper.Replace(cell)
+	}
+	return res, nil
+}
+
+// Describe implements selina.Describer interface
+func (e *Encoder) Describe() (string, map[string]interface{}, error) {
+	if e.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "encode"}
+	if len(e.opts.Header) > 0 {
+		args["header"] = e.opts.Header
+	}
+	if e.opts.NoHeader {
+		args["no_header"] = true
+	}
+	if e.opts.Null != "" {
+		args["null_text"] = e.opts.Null
+	}
+	if err := selina.DescribeFormat(args, "read_format", e.opts.ReadFormat); err != nil {
+		return "", nil, err
+	}
+	return "tsv", args, nil
+}
+
+var _ selina.Worker = (*Decoder)(nil)
+
+// DecoderOptions configure tsv decoding
+type DecoderOptions struct {
+	Header []string
+	// HeaderFromFirst take header from first line, when Header is
+	// not empty first line is skipped and Header is used instead
+	HeaderFromFirst bool
+	Codec           selina.Marshaler
+	Handler         selina.ErrorHandler
+	// Null fields equal to this text are null (i.e. \N), default no field is null
+	Null string
+	// Types of columns, default csv.TypeString, empty fields of a column
+	// with a type other than csv.TypeString are null
+	Types map[string]csv.ColumnType
+}
+
+// Check if a combination of options is valid
+func (o DecoderOptions) Check() error {
+	if len(o.Header) == 0 && !o.HeaderFromFirst {
+		return ErrNoHeader
+	}
+	if err := checkNull(o.Null); err != nil {
+		return err
+	}
+	for col, t := range o.Types {
+		if err := t.Check(); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// Decoder parse tsv lines into key value pairs
+type Decoder struct {
+	opts DecoderOptions
+}
+
+// NewDecoder returns a new Decoder with given options
+func NewDecoder(opts DecoderOptions) *Decoder {
+	return &Decoder{opts: opts}
+}
+
+// Process implements selina.Worker interface
+func (d *Decoder) Process(ctx context.Context, args selina.ProcessArgs) error {
+	defer close(args.Output)
+	if args.Input == nil {
+		return selina.ErrNilUpstream
+	}
+	if err := d.opts.Check(); err != nil {
+		return err
+	}
+	header := d.opts.Header
+	needHeader := d.opts.HeaderFromFirst
+	codec := selina.DefaultMarshaler
+	if d.opts.Codec != nil {
+		codec = d.opts.Codec
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-args.Input:
+			if !ok {
+				return nil
+			}
+			var nb *bytes.Buffer
+			var skip bool
+			err := selina.HandleMessage(ctx, msg.Bytes(), d.opts.Handler, func() error {
+				row := strings.Split(strings.TrimSuffix(msg.String(), "\r"), "\t")
+				if needHeader {
+					needHeader = false
+					skip = true
+					if len(header) == 0 {
+						for _, cell := range row {
+							header = append(header, unescaper.Replace(cell))
+						}
+					}
+					return nil
+				}
+				res, err := d.record(header, row)
+				if err != nil {
+					return err
+				}
+				b, err := codec(res)
+				if err != nil {
+					return fmt.Errorf("encoding %w", err)
+				}
+				nb = selina.GetBuffer()
+				nb.Write(b)
+				return nil
+			})
+			if nb == nil {
+				if skip {
+					// header is processed without output
+					selina.Ack(msg, nil)
+				}
+				selina.FreeBuffer(msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			selina.Forward(msg, nb)
+			selina.FreeBuffer(msg)
+			if err := selina.SendContext(ctx, nb, args.Output); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record convert row into key value pairs using header
+func (d *Decoder) record(header, row []string) (map[string]interface{}, error) {
+	if len(row) != len(header) {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrFieldCount, len(row), len(header))
+	}
+	res := make(map[string]interface{}, len(header))
+	for i, cell := range row {
+		name := header[i]
+		if d.opts.Null != "" && cell == d.opts.Null {
+			res[name] = nil
+			continue
+		}
+		t, ok := d.opts.Types[name]
+		if !ok {
+			t = csv.TypeString
+		}
+		value, err := t.Parse(unescaper.Replace(cell))
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		res[name] = value
+	}
+	return res, nil
+}
+
+// Describe implements selina.Describer interface
+func (d *Decoder) Describe() (string, map[string]interface{}, error) {
+	if d.opts.Handler != nil {
+		return "", nil, fmt.Errorf("%w: custom Handler", selina.ErrNotDescribable)
+	}
+	args := map[string]interface{}{"mode": "decode"}
+	if len(d.opts.Header) > 0 {
+		args["header"] = d.opts.Header
+	}
+	if d.opts.HeaderFromFirst {
+		args["header_from_first"] = true
+	}
+	if d.opts.Null != "" {
+		args["null_text"] = d.opts.Null
+	}
+	if len(d.opts.Types) > 0 {
+		types := make(map[string]string, len(d.opts.Types))
+		for col, t := range d.opts.Types {
+			types[col] = string(t)
+		}
+		args["types"] = types
+	}
+	if err := selina.DescribeFormat(args, "write_format", d.opts.Codec); err != nil {
+		return "", nil, err
+	}
+	return "tsv", args, nil
+}
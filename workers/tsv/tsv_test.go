@@ -0,0 +1,229 @@
+package tsv_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/licaonfee/selina"
+	"github.com/licaonfee/selina/workers"
+	"github.com/licaonfee/selina/workers/csv"
+	"github.com/licaonfee/selina/workers/tsv"
+)
+
+func run(w selina.Worker, input []string) ([]string, error) {
+	output := make(chan *bytes.Buffer, len(input)+1)
+	args := selina.ProcessArgs{Input: selina.SliceAsChannelOfBuffer(input, true), Output: output}
+	err := w.Process(context.Background(), args)
+	got := []string{}
+	for _, b := range selina.ChannelAsSlice(output) {
+		got = append(got, b.String())
+	}
+	return got, err
+}
+
+func TestEncoderProcess(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  tsv.EncoderOptions
+		input []string
+		want  []string
+	}{
+		{
+			name:  "Auto header",
+			opts:  tsv.EncoderOptions{},
+			input: []string{`{"name":"Selina","id":0}`, `{"name":"Lizbeth","id":1.5}`},
+			want:  []string{"id\tname", "0\tSelina", "1.5\tLizbeth"},
+		},
+		{
+			name:  "Escape",
+			opts:  tsv.EncoderOptions{Header: []string{"a", "b"}},
+			input: []string{`{"a":"x\ty","b":"1\n2\\3"}`},
+			want:  []string{"a\tb", `x\ty` + "\t" + `1\n2\\3`},
+		},
+		{
+			name:  "Null and missing",
+			opts:  tsv.EncoderOptions{Header: []string{"a", "b", "c"}, Null: `\N`, NoHeader: true},
+			input: []string{`{"a":null,"c":[true]}`},
+			want:  []string{`\N` + "\t\t[true]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(tsv.NewEncoder(tt.opts), tt.input)
+			if err != nil {
+				t.Fatalf("Process() err = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Process() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    tsv.DecoderOptions
+		input   []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "Header from first",
+			opts:  tsv.DecoderOptions{HeaderFromFirst: true},
+			input: []string{"id\tname", "0\tSelina\r"},
+			want:  []string{`{"id":"0","name":"Selina"}`},
+		},
+		{
+			name:  "Unescape",
+			opts:  tsv.DecoderOptions{Header: []string{"a", "b"}},
+			input: []string{`x\ty` + "\t" + `1\n2\\3\q`},
+			want:  []string{`{"a":"x\ty","b":"1\n2\\3\\q"}`},
+		},
+		{
+			name:  "Types and nulls",
+			opts:  tsv.DecoderOptions{Header: []string{"id", "ok", "name"}, Null: `\N`, Types: map[string]csv.ColumnType{"id": csv.TypeInt, "ok": csv.TypeBool}},
+			input: []string{"1\ttrue\t" + `\N`, "\t\t"},
+			want:  []string{`{"id":1,"name":null,"ok":true}`, `{"id":null,"name":"","ok":null}`},
+		},
+		{
+			name:    "Field count",
+			opts:    tsv.DecoderOptions{Header: []string{"a", "b"}},
+			input:   []string{"1\t2\t3"},
+			want:    []string{},
+			wantErr: tsv.ErrFieldCount,
+		},
+		{
+			name:    "No header",
+			opts:    tsv.DecoderOptions{},
+			input:   []string{"1"},
+			want:    []string{},
+			wantErr: tsv.ErrNoHeader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(tsv.NewDecoder(tt.opts), tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process() err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Process() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	record := `{"a":"tab\there","b":"new\nline\\"}`
+	lines, err := run(tsv.NewEncoder(tsv.EncoderOptions{}), []string{record})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := run(tsv.NewDecoder(tsv.DecoderOptions{HeaderFromFirst: true}), lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != record {
+		t.Fatalf("round trip got = %q, want %q", got, record)
+	}
+}
+
+func TestDecoderProcessHeaderAck(t *testing.T) {
+	var acks []error
+	input := make(chan *bytes.Buffer, 2)
+	for _, line := range []string{"id", "1"} {
+		msg := selina.GetBuffer()
+		msg.WriteString(line)
+		selina.OnAck(msg, func(err error) { acks = append(acks, err) })
+		input <- msg
+	}
+	close(input)
+	output := make(chan *bytes.Buffer, 1)
+	d := tsv.NewDecoder(tsv.DecoderOptions{HeaderFromFirst: true})
+	if err := d.Process(context.Background(), selina.ProcessArgs{Input: input, Output: output}); err != nil {
+		t.Fatalf("Process() err = %v", err)
+	}
+	out := <-output
+	selina.Ack(out, nil)
+	selina.FreeBuffer(out)
+	if len(acks) != 2 || acks[0] != nil || acks[1] != nil {
+		t.Fatalf("header must be acknowledged, acks = %v", acks)
+	}
+}
+
+func TestOptionsCheck(t *testing.T) {
+	if err := (tsv.EncoderOptions{Null: "\t"}).Check(); err == nil {
+		t.Fatal("Check() must fail with a tab in null text")
+	}
+	if err := (tsv.EncoderOptions{Header: []string{"a", "a"}}).Check(); err == nil {
+		t.Fatal("Check() must fail with a duplicated field")
+	}
+	if err := (tsv.DecoderOptions{Header: []string{"a"}, Types: map[string]csv.ColumnType{"a": "date"}}).Check(); err == nil {
+		t.Fatal("Check() must fail with an invalid column type")
+	}
+	if err := (tsv.EncoderOptions{Null: `\N`}).Check(); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+}
+
+func TestEncoderProcessCancelation(t *testing.T) {
+	if err := workers.ATProcessCancel(tsv.NewEncoder(tsv.EncoderOptions{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessCloseInput(t *testing.T) {
+	if err := workers.ATProcessCloseInput(tsv.NewEncoder(tsv.EncoderOptions{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessNilUpstream(t *testing.T) {
+	if err := workers.ATProcessNilUpstream(tsv.NewEncoder(tsv.EncoderOptions{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderProcessErrorHandler(t *testing.T) {
+	newEncoder := func(h selina.ErrorHandler) selina.Worker {
+		return tsv.NewEncoder(tsv.EncoderOptions{Header: []string{"name"}, Handler: h})
+	}
+	if err := workers.ATProcessErrorHandler(newEncoder, []string{`{"name":"a"}`, `{"name"`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessCancelation(t *testing.T) {
+	if err := workers.ATProcessCancel(tsv.NewDecoder(tsv.DecoderOptions{HeaderFromFirst: true})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessCloseInput(t *testing.T) {
+	if err := workers.ATProcessCloseInput(tsv.NewDecoder(tsv.DecoderOptions{HeaderFromFirst: true})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderProcessFreeBuffers(t *testing.T) {
+	w := tsv.NewDecoder(tsv.DecoderOptions{Header: []string{"name"}})
+	if err := workers.ATProcessFreeBuffers(w, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	d := tsv.NewDecoder(tsv.DecoderOptions{HeaderFromFirst: true, Null: `\N`, Types: map[string]csv.ColumnType{"id": csv.TypeInt}})
+	typ, args, err := d.Describe()
+	if err != nil {
+		t.Fatalf("Describe() err = %v", err)
+	}
+	want := map[string]interface{}{"mode": "decode", "header_from_first": true, "null_text": `\N`, "types": map[string]string{"id": "int"}}
+	if typ != "tsv" || !reflect.DeepEqual(args, want) {
+		t.Fatalf("Describe() got = %s %v, want %v", typ, args, want)
+	}
+}